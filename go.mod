@@ -9,6 +9,7 @@ require (
 	github.com/grasp-labs/ds-event-stream-go-sdk v1.1.0
 	github.com/grasp-labs/ds-go-commonmodels/v3 v3.3.5
 	github.com/labstack/echo/v4 v4.15.3
+	github.com/segmentio/kafka-go v0.4.50
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/text v0.38.0
 )
@@ -21,7 +22,6 @@ require (
 	github.com/mattn/go-isatty v0.0.22 // indirect
 	github.com/pierrec/lz4/v4 v4.1.25 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/segmentio/kafka-go v0.4.50 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
 	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
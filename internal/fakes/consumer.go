@@ -0,0 +1,34 @@
+package fakes
+
+import (
+	"context"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// MockConsumer is a test double for interfaces.Consumer that hands out the
+// messages pushed with Push, on any topic.
+type MockConsumer struct {
+	ch chan interfaces.Message
+}
+
+// NewMockConsumer returns a consumer that can buffer up to size messages.
+func NewMockConsumer(size int) *MockConsumer {
+	return &MockConsumer{ch: make(chan interfaces.Message, size)}
+}
+
+// Push queues a message with the given value.
+func (m *MockConsumer) Push(value any) {
+	m.ch <- interfaces.Message{Value: value}
+}
+
+func (m *MockConsumer) Receive(ctx context.Context, _ string) (interfaces.Message, error) {
+	select {
+	case msg := <-m.ch:
+		return msg, nil
+	case <-ctx.Done():
+		return interfaces.Message{}, ctx.Err()
+	}
+}
+
+func (m *MockConsumer) Close() error { return nil }
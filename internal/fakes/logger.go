@@ -26,3 +26,9 @@ func (l *MockLogger) Error(ctx context.Context, format string, args ...any) {
 	l.errorCalled = true
 	l.lastMsg = fmt.Sprintf(format, args...)
 }
+
+// WarningCalled reports whether Warning was called.
+func (l *MockLogger) WarningCalled() bool { return l.warningCalled }
+
+// LastMessage returns the most recently logged message.
+func (l *MockLogger) LastMessage() string { return l.lastMsg }
@@ -1,6 +1,7 @@
 package fakes
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -20,3 +21,12 @@ func GenerateRSAPairPEM() (*rsa.PrivateKey, string, error) {
 	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
 	return priv, string(pubPEM), nil
 }
+
+// PublicKeyPEM encodes any public key as a PKIX "PUBLIC KEY" PEM block.
+func PublicKeyPEM(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
@@ -0,0 +1,171 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"time"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// CloudEventsMode selects how events are encoded as CloudEvents 1.0.
+type CloudEventsMode int
+
+const (
+	// CloudEventsStructured sends the whole CloudEvent as the message value
+	// (JSON, content-type application/cloudevents+json).
+	CloudEventsStructured CloudEventsMode = iota
+	// CloudEventsBinary sends the event payload as the message value and the
+	// CloudEvents attributes as ce_* headers (Kafka protocol binding). It needs
+	// a producer that writes headers, i.e. an interfaces.MessageProducer, and
+	// one that sets content-type application/json, as KafkaProducerWrapper does.
+	CloudEventsBinary
+)
+
+// Content types and the header carrying them.
+const (
+	HeaderContentType             = "content-type"
+	CloudEventsContentType        = "application/cloudevents+json"
+	CloudEventsDataContentType    = "application/json"
+	cloudEventsSpecVersion        = "1.0"
+	cloudEventsBinaryHeaderPrefix = "ce_"
+)
+
+// CloudEvent is the CloudEvents 1.0 form of an sdkmodels.EventJson. Source is
+// the EventJson EventSource (the service principal id); Subject is the payload's
+// "subject" field or, failing that, AffectedEntityUri. The tenant, request and
+// session ids are carried as extension attributes.
+type CloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype,omitempty"`
+	TenantID        string    `json:"tenantid,omitempty"`
+	RequestID       string    `json:"requestid,omitempty"`
+	SessionID       string    `json:"sessionid,omitempty"`
+	Data            any       `json:"data,omitempty"`
+}
+
+// ToCloudEvent maps ev to a CloudEvent.
+func ToCloudEvent(ev sdkmodels.EventJson) CloudEvent {
+	ce := CloudEvent{
+		SpecVersion: cloudEventsSpecVersion,
+		ID:          ev.Id.String(),
+		Source:      ev.EventSource,
+		Type:        ev.EventType,
+		Subject:     payloadSubject(ev.Payload),
+		Time:        ev.Timestamp.UTC(),
+		TenantID:    idString(ev.TenantId),
+		RequestID:   idString(ev.RequestId),
+		SessionID:   idString(ev.SessionId),
+		Data:        ev.Payload,
+	}
+	if ce.Subject == "" && ev.AffectedEntityUri != nil {
+		ce.Subject = *ev.AffectedEntityUri
+	}
+	if ce.Data != nil {
+		ce.DataContentType = CloudEventsDataContentType
+	}
+	return ce
+}
+
+// binaryHeaders returns the ce_* headers for the CloudEvents Kafka binding.
+// The data is always JSON, so content-type is left to the producer, which
+// sends application/json by default.
+func (ce CloudEvent) binaryHeaders() map[string]string {
+	h := map[string]string{
+		cloudEventsBinaryHeaderPrefix + "specversion": ce.SpecVersion,
+		cloudEventsBinaryHeaderPrefix + "id":          ce.ID,
+		cloudEventsBinaryHeaderPrefix + "source":      ce.Source,
+		cloudEventsBinaryHeaderPrefix + "type":        ce.Type,
+		cloudEventsBinaryHeaderPrefix + "time":        ce.Time.Format(time.RFC3339Nano),
+	}
+	for name, v := range map[string]string{
+		"subject":   ce.Subject,
+		"tenantid":  ce.TenantID,
+		"requestid": ce.RequestID,
+		"sessionid": ce.SessionID,
+	} {
+		if v != "" {
+			h[cloudEventsBinaryHeaderPrefix+name] = v
+		}
+	}
+	return h
+}
+
+// CloudEventsProducer re-encodes every sdkmodels.EventJson as a CloudEvent
+// before handing it to the wrapped producer; other values pass through. Wrap
+// individual FanOut sinks to choose the encoding per sink, or use
+// WithCloudEvents to encode everything an adapter sends.
+type CloudEventsProducer struct {
+	inner interfaces.Producer
+	mode  CloudEventsMode
+}
+
+var _ interfaces.MessageProducer = (*CloudEventsProducer)(nil)
+
+// NewCloudEventsProducer wraps inner with the given encoding.
+func NewCloudEventsProducer(inner interfaces.Producer, mode CloudEventsMode) *CloudEventsProducer {
+	return &CloudEventsProducer{inner: inner, mode: mode}
+}
+
+func (p *CloudEventsProducer) Send(ctx context.Context, topic string, value any) error {
+	return p.SendMessage(ctx, topic, interfaces.Message{Value: value})
+}
+
+// SendMessage encodes msg.Value and adds the CloudEvents headers to any the
+// message already carries. The key is kept.
+func (p *CloudEventsProducer) SendMessage(ctx context.Context, topic string, msg interfaces.Message) error {
+	ev, ok := msg.Value.(sdkmodels.EventJson)
+	if !ok {
+		return sendMessage(ctx, p.inner, topic, msg)
+	}
+	ce := ToCloudEvent(ev)
+
+	out := interfaces.Message{Key: msg.Key, Headers: maps.Clone(msg.Headers)}
+	if out.Headers == nil {
+		out.Headers = map[string]string{}
+	}
+	switch p.mode {
+	case CloudEventsBinary:
+		maps.Copy(out.Headers, ce.binaryHeaders())
+		out.Value = ce.Data
+	default:
+		out.Headers[HeaderContentType] = CloudEventsContentType
+		out.Value = ce
+	}
+	return sendMessage(ctx, p.inner, topic, out)
+}
+
+// dropsKeys reports whether the wrapped producer ignores message keys.
+func (p *CloudEventsProducer) dropsKeys() bool {
+	kd, ok := p.inner.(keyDropper)
+	return ok && kd.dropsKeys()
+}
+
+func (p *CloudEventsProducer) Close() error {
+	return p.inner.Close()
+}
+
+// payloadSubject returns the "subject" field of a JSON object payload.
+func payloadSubject(payload any) string {
+	if payload == nil {
+		return ""
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return ""
+	}
+	var s struct {
+		Subject string `json:"subject"`
+	}
+	if json.Unmarshal(b, &s) != nil {
+		return ""
+	}
+	return s.Subject
+}
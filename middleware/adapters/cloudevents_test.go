@@ -0,0 +1,116 @@
+package adapters_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+func cloudEventsFixture() sdkmodels.EventJson {
+	return sdkmodels.EventJson{
+		Id:          uuid.New(),
+		RequestId:   uuid.New(),
+		SessionId:   uuid.New(),
+		TenantId:    uuid.New(),
+		EventType:   "login.success",
+		EventSource: "dp.core.test-svc.v1",
+		Timestamp:   time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Payload:     map[string]any{"subject": "user@email.com", "path": "/x"},
+	}
+}
+
+func TestCloudEventsProducer_Structured(t *testing.T) {
+	mp := &messageProducer{}
+	p := adapters.NewCloudEventsProducer(mp, adapters.CloudEventsStructured)
+	ev := cloudEventsFixture()
+
+	require.NoError(t, p.SendMessage(context.Background(), "events", interfaces.Message{
+		Key:     "k",
+		Headers: map[string]string{adapters.HeaderEventType: ev.EventType},
+		Value:   ev,
+	}))
+
+	msgs := mp.messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "k", msgs[0].Key)
+	assert.Equal(t, adapters.CloudEventsContentType, msgs[0].Headers[adapters.HeaderContentType])
+	assert.Equal(t, ev.EventType, msgs[0].Headers[adapters.HeaderEventType])
+
+	b, err := json.Marshal(msgs[0].Value)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"specversion": "1.0",
+		"id": "`+ev.Id.String()+`",
+		"source": "dp.core.test-svc.v1",
+		"type": "login.success",
+		"subject": "user@email.com",
+		"time": "2024-05-01T10:00:00Z",
+		"datacontenttype": "application/json",
+		"tenantid": "`+ev.TenantId.String()+`",
+		"requestid": "`+ev.RequestId.String()+`",
+		"sessionid": "`+ev.SessionId.String()+`",
+		"data": {"subject": "user@email.com", "path": "/x"}
+	}`, string(b))
+}
+
+func TestCloudEventsProducer_Binary(t *testing.T) {
+	mp := &messageProducer{}
+	p := adapters.NewCloudEventsProducer(mp, adapters.CloudEventsBinary)
+	ev := cloudEventsFixture()
+
+	require.NoError(t, p.Send(context.Background(), "events", ev))
+
+	msgs := mp.messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, ev.Payload, msgs[0].Value)
+	assert.Equal(t, map[string]string{
+		"ce_specversion": "1.0",
+		"ce_id":          ev.Id.String(),
+		"ce_source":      "dp.core.test-svc.v1",
+		"ce_type":        "login.success",
+		"ce_subject":     "user@email.com",
+		"ce_time":        "2024-05-01T10:00:00Z",
+		"ce_tenantid":    ev.TenantId.String(),
+		"ce_requestid":   ev.RequestId.String(),
+		"ce_sessionid":   ev.SessionId.String(),
+	}, msgs[0].Headers)
+}
+
+func TestCloudEventsProducer_PerSinkInFanOut(t *testing.T) {
+	plain := adapters.NewMemorySink(4)
+	ce := adapters.NewMemorySink(4)
+	f, err := adapters.NewFanOut(adapters.FanOutRoute{
+		Pattern: "login.*",
+		Sinks:   []interfaces.Producer{plain, adapters.NewCloudEventsProducer(ce, adapters.CloudEventsStructured)},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, f.Send(context.Background(), "events", cloudEventsFixture()))
+
+	require.Len(t, plain.Records(), 1)
+	require.Len(t, ce.Records(), 1)
+	assert.IsType(t, sdkmodels.EventJson{}, plain.Records()[0].Value)
+	assert.IsType(t, adapters.CloudEvent{}, ce.Records()[0].Value)
+}
+
+func TestWithCloudEvents_PassesThroughOtherValues(t *testing.T) {
+	fp := &flakyProducer{}
+	adapter := newAdapter(t, fp, adapters.WithCloudEvents(adapters.CloudEventsStructured))
+
+	require.NoError(t, adapter.Send(context.Background(), "events", "raw"))
+	require.NoError(t, adapter.Send(context.Background(), "events", cloudEventsFixture()))
+
+	msgs := fp.messages()
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "raw", msgs[0].value)
+	assert.IsType(t, adapters.CloudEvent{}, msgs[1].value)
+}
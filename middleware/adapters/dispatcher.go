@@ -0,0 +1,379 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// OverflowPolicy decides what the Dispatcher does when its queue is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest rejects the event being enqueued and keeps the queue as is.
+	DropNewest OverflowPolicy = iota
+	// DropOldest evicts the oldest queued event to make room for the new one.
+	DropOldest
+	// Block waits up to DispatcherConfig.BlockTimeout for room in the queue,
+	// then drops the new event.
+	Block
+)
+
+// Dispatcher defaults. The queue is sized for short broker hiccups; a sustained
+// outage will hit the overflow policy rather than grow memory without bound.
+const (
+	defaultDispatchWorkers   = 4
+	defaultDispatchQueueSize = 1024
+	defaultBlockTimeout      = 50 * time.Millisecond
+	defaultSendTimeout       = 3 * time.Second
+
+	// flushPollInterval is how often Flush re-checks the pending count.
+	flushPollInterval = 10 * time.Millisecond
+)
+
+// DispatcherConfig tunes a Dispatcher. Zero values fall back to the defaults.
+type DispatcherConfig struct {
+	Workers      int            // number of sending goroutines
+	QueueSize    int            // capacity of the pending-event queue
+	Overflow     OverflowPolicy // behaviour when the queue is full
+	BlockTimeout time.Duration  // max wait under the Block policy
+	SendTimeout  time.Duration  // per-attempt bound on producer.Send (see RetryPolicy.AttemptTimeout)
+
+	// Retry is applied to every failed send; the zero value sends once.
+	Retry RetryPolicy
+	// DeadLetterTopic, when set, receives events whose retries are exhausted.
+	DeadLetterTopic string
+}
+
+func (c DispatcherConfig) withDefaults() DispatcherConfig {
+	if c.Workers <= 0 {
+		c.Workers = defaultDispatchWorkers
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultDispatchQueueSize
+	}
+	if c.BlockTimeout <= 0 {
+		c.BlockTimeout = defaultBlockTimeout
+	}
+	if c.SendTimeout <= 0 {
+		c.SendTimeout = defaultSendTimeout
+	}
+	if c.Retry.AttemptTimeout <= 0 {
+		c.Retry.AttemptTimeout = c.SendTimeout
+	}
+	return c
+}
+
+// DispatcherStats is a point-in-time snapshot of the Dispatcher counters.
+type DispatcherStats struct {
+	Enqueued     uint64 // accepted into the queue
+	Sent         uint64 // delivered by the producer
+	Dropped      uint64 // discarded by the overflow policy
+	Failed       uint64 // rejected by the producer after all retries
+	Retried      uint64 // extra attempts made after a failed send
+	DeadLettered uint64 // failed events delivered to the dead-letter topic
+	Abandoned    uint64 // still pending when Shutdown's deadline expired (not counted as Failed)
+}
+
+// dispatchJob is a single queued event.
+type dispatchJob struct {
+	ctx   context.Context
+	topic string
+	msg   interfaces.Message
+	onErr func(ctx context.Context, err error)
+}
+
+// Dispatcher delivers events to a Producer from a fixed pool of workers fed by a
+// bounded queue, so a slow broker costs a known amount of memory and goroutines
+// instead of one goroutine per event.
+type Dispatcher struct {
+	producer interfaces.Producer
+	cfg      DispatcherConfig
+	queue    chan dispatchJob
+
+	// mu orders Enqueue against Shutdown: once closed is set no new event is
+	// counted as pending, so a drained pending count is final.
+	mu       sync.RWMutex
+	closed   bool
+	stop     chan struct{}      // closed to release the workers
+	abortCtx context.Context    // cancelled to abort in-flight sends
+	abort    context.CancelFunc // cancels abortCtx
+
+	// shutdownOnce runs Shutdown once; later calls return its result.
+	shutdownOnce sync.Once
+	shutdownN    int
+	shutdownErr  error
+
+	// pending counts events accepted but not yet finished (queued or sending).
+	pending atomic.Int64
+
+	enqueued     atomic.Uint64
+	sent         atomic.Uint64
+	dropped      atomic.Uint64
+	failed       atomic.Uint64
+	retried      atomic.Uint64
+	deadLettered atomic.Uint64
+	abandoned    atomic.Uint64
+}
+
+// NewDispatcher starts cfg.Workers workers sending to producer.
+func NewDispatcher(producer interfaces.Producer, cfg DispatcherConfig) *Dispatcher {
+	cfg = cfg.withDefaults()
+	abortCtx, abort := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		producer: producer,
+		cfg:      cfg,
+		queue:    make(chan dispatchJob, cfg.QueueSize),
+		stop:     make(chan struct{}),
+		abortCtx: abortCtx,
+		abort:    abort,
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// Enqueue schedules value for delivery to topic. The request context is detached
+// from cancellation (request-scoped values are kept) so the send outlives the
+// HTTP response. onErr, if set, is called from a worker when the send fails
+// after all retries.
+// Returns false when the event was dropped by the overflow policy or because the
+// Dispatcher is shutting down.
+func (d *Dispatcher) Enqueue(ctx context.Context, topic string, value any, onErr func(ctx context.Context, err error)) bool {
+	return d.EnqueueMessage(ctx, topic, interfaces.Message{Value: value}, onErr)
+}
+
+// EnqueueMessage is Enqueue for a value with a key and headers. They reach
+// producers implementing interfaces.MessageProducer; others receive msg.Value.
+func (d *Dispatcher) EnqueueMessage(ctx context.Context, topic string, msg interfaces.Message, onErr func(ctx context.Context, err error)) bool {
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		d.dropped.Add(1)
+		return false
+	}
+	d.pending.Add(1)
+	d.mu.RUnlock()
+
+	job := dispatchJob{
+		ctx:   context.WithoutCancel(ctx),
+		topic: topic,
+		msg:   msg,
+		onErr: onErr,
+	}
+
+	select {
+	case d.queue <- job:
+		d.enqueued.Add(1)
+		return true
+	default:
+	}
+
+	switch d.cfg.Overflow {
+	case DropOldest:
+		for {
+			select {
+			case d.queue <- job:
+				d.enqueued.Add(1)
+				return true
+			default:
+			}
+			// Evict the head to make room; another producer may win the slot,
+			// in which case we simply go round again.
+			select {
+			case <-d.queue:
+				d.pending.Add(-1)
+				d.dropped.Add(1)
+			default:
+			}
+		}
+	case Block:
+		timer := time.NewTimer(d.cfg.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case d.queue <- job:
+			d.enqueued.Add(1)
+			return true
+		case <-timer.C:
+		case <-d.stop:
+		}
+	}
+
+	d.pending.Add(-1)
+	d.dropped.Add(1)
+	return false
+}
+
+// Flush waits until every accepted event has been sent or failed, or until ctx
+// is done, in which case ctx.Err() is returned. New events are still accepted
+// while flushing.
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	if d.pending.Load() == 0 {
+		return nil
+	}
+	ticker := time.NewTicker(flushPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if d.pending.Load() == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// Shutdown stops accepting events and waits for the pending ones to finish. If
+// ctx expires first, in-flight sends are cancelled and whatever is still queued
+// is discarded; the number of events lost that way is returned together with
+// ctx.Err(). They count as Abandoned only: they are not Failed, passed to onErr
+// or dead-lettered. Shutdown does not close the producer and is safe to call
+// again: later calls return the first call's result without waiting.
+func (d *Dispatcher) Shutdown(ctx context.Context) (int, error) {
+	d.shutdownOnce.Do(func() {
+		d.shutdownN, d.shutdownErr = d.shutdown(ctx)
+	})
+	return d.shutdownN, d.shutdownErr
+}
+
+func (d *Dispatcher) shutdown(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	err := d.Flush(ctx)
+	abandoned := 0
+	if err != nil {
+		abandoned = int(d.pending.Load())
+		d.abandoned.Add(uint64(abandoned))
+		d.abort()
+	}
+	close(d.stop)
+	if err != nil {
+		// The workers are gone; discard what they left queued so pending
+		// only counts the aborted in-flight sends, which finish shortly.
+		for drained := false; !drained; {
+			select {
+			case <-d.queue:
+				d.pending.Add(-1)
+			default:
+				drained = true
+			}
+		}
+	}
+	return abandoned, err
+}
+
+// Closed reports whether Shutdown has been called, after which Enqueue drops
+// every event.
+func (d *Dispatcher) Closed() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.closed
+}
+
+// Stats returns the current counters.
+func (d *Dispatcher) Stats() DispatcherStats {
+	return DispatcherStats{
+		Enqueued:     d.enqueued.Load(),
+		Sent:         d.sent.Load(),
+		Dropped:      d.dropped.Load(),
+		Failed:       d.failed.Load(),
+		Retried:      d.retried.Load(),
+		DeadLettered: d.deadLettered.Load(),
+		Abandoned:    d.abandoned.Load(),
+	}
+}
+
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case <-d.stop:
+			return
+		case job := <-d.queue:
+			d.send(job)
+		}
+	}
+}
+
+func (d *Dispatcher) send(job dispatchJob) {
+	defer d.pending.Add(-1)
+	if d.abortCtx.Err() != nil {
+		return // picked up after Shutdown gave up: already counted as abandoned
+	}
+
+	var (
+		err      error
+		attempts int
+		retry    = d.cfg.Retry
+	)
+	for attempts = 1; ; attempts++ {
+		if err = d.attempt(job.ctx, job.topic, job.msg); err == nil {
+			d.sent.Add(1)
+			return
+		}
+		if attempts >= retry.attempts() || !d.sleep(retry.backoff(attempts)) {
+			break
+		}
+		d.retried.Add(1)
+	}
+
+	// A Shutdown that ran out of time already counted this event as
+	// abandoned; it was not really given up on, so don't report or
+	// dead-letter it.
+	if d.abortCtx.Err() != nil {
+		return
+	}
+
+	d.failed.Add(1)
+	if attempts > 1 {
+		err = fmt.Errorf("after %d attempts: %w", attempts, err)
+	}
+	if d.cfg.DeadLetterTopic != "" {
+		dl := job.msg
+		dl.Value = deadLetterValue(job.msg.Value, job.topic, err, attempts, time.Now())
+		if dlErr := d.attempt(job.ctx, d.cfg.DeadLetterTopic, dl); dlErr != nil {
+			err = fmt.Errorf("%w (dead-letter to %s failed: %v)", err, d.cfg.DeadLetterTopic, dlErr)
+		} else {
+			d.deadLettered.Add(1)
+		}
+	}
+	if job.onErr != nil {
+		job.onErr(job.ctx, err)
+	}
+}
+
+// attempt makes a single producer.Send bounded by the attempt timeout and
+// aborted by a Shutdown that runs out of time.
+func (d *Dispatcher) attempt(ctx context.Context, topic string, msg interfaces.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Retry.AttemptTimeout)
+	defer cancel()
+	stopAbort := context.AfterFunc(d.abortCtx, cancel)
+	defer stopAbort()
+	return sendMessage(ctx, d.producer, topic, msg)
+}
+
+// sleep waits for the backoff; it returns false if the Dispatcher was aborted.
+func (d *Dispatcher) sleep(wait time.Duration) bool {
+	if d.abortCtx.Err() != nil {
+		return false
+	}
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-d.abortCtx.Done():
+		return false
+	}
+}
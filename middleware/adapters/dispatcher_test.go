@@ -0,0 +1,282 @@
+package adapters_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+)
+
+// gatedProducer blocks every Send until release is closed, recording values in
+// delivery order. Each Send signals entered first, so tests can tell when a
+// worker is busy. err, when set, is returned from every Send.
+type gatedProducer struct {
+	mu      sync.Mutex
+	entered chan struct{}
+	release chan struct{}
+	values  []any
+	err     error
+	closed  bool
+}
+
+func newGatedProducer() *gatedProducer {
+	return &gatedProducer{entered: make(chan struct{}, 64), release: make(chan struct{})}
+}
+
+func (p *gatedProducer) Send(ctx context.Context, topic string, value any) error {
+	p.entered <- struct{}{}
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.values = append(p.values, value)
+	return p.err
+}
+
+func (p *gatedProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func (p *gatedProducer) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+func (p *gatedProducer) sent() []any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]any(nil), p.values...)
+}
+
+// awaitBusy waits until a worker has picked up an event and is blocked in Send.
+func (p *gatedProducer) awaitBusy(t *testing.T) {
+	t.Helper()
+	select {
+	case <-p.entered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker never entered Send")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	require.Eventually(t, cond, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestDispatcher_DeliversAndCounts(t *testing.T) {
+	p := newGatedProducer()
+	close(p.release)
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{Workers: 2, QueueSize: 8})
+
+	for i := 0; i < 5; i++ {
+		assert.True(t, d.Enqueue(context.Background(), "topic", i, nil))
+	}
+	waitFor(t, func() bool { return d.Stats().Sent == 5 })
+	assert.Equal(t, uint64(5), d.Stats().Enqueued)
+	assert.Zero(t, d.Stats().Dropped)
+}
+
+func TestDispatcher_DropNewestWhenFull(t *testing.T) {
+	p := newGatedProducer()
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{Workers: 1, QueueSize: 1, Overflow: adapters.DropNewest})
+
+	// One event held by the worker, one in the queue, the third is dropped.
+	assert.True(t, d.Enqueue(context.Background(), "topic", 1, nil))
+	p.awaitBusy(t)
+	assert.True(t, d.Enqueue(context.Background(), "topic", 2, nil))
+	assert.False(t, d.Enqueue(context.Background(), "topic", 3, nil))
+
+	close(p.release)
+	waitFor(t, func() bool { return d.Stats().Sent == 2 })
+	assert.Equal(t, []any{1, 2}, p.sent())
+	assert.Equal(t, uint64(1), d.Stats().Dropped)
+}
+
+func TestDispatcher_DropOldestWhenFull(t *testing.T) {
+	p := newGatedProducer()
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{Workers: 1, QueueSize: 1, Overflow: adapters.DropOldest})
+
+	assert.True(t, d.Enqueue(context.Background(), "topic", 1, nil))
+	p.awaitBusy(t)
+	assert.True(t, d.Enqueue(context.Background(), "topic", 2, nil))
+	// Queue is full with 2; 3 evicts it.
+	assert.True(t, d.Enqueue(context.Background(), "topic", 3, nil))
+
+	close(p.release)
+	waitFor(t, func() bool { return d.Stats().Sent == 2 })
+	assert.Equal(t, []any{1, 3}, p.sent())
+	assert.Equal(t, uint64(1), d.Stats().Dropped)
+}
+
+func TestDispatcher_BlockGivesUpAfterDeadline(t *testing.T) {
+	p := newGatedProducer()
+	defer close(p.release)
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{
+		Workers:      1,
+		QueueSize:    1,
+		Overflow:     adapters.Block,
+		BlockTimeout: 20 * time.Millisecond,
+	})
+
+	assert.True(t, d.Enqueue(context.Background(), "topic", 1, nil))
+	p.awaitBusy(t)
+	assert.True(t, d.Enqueue(context.Background(), "topic", 2, nil))
+
+	start := time.Now()
+	assert.False(t, d.Enqueue(context.Background(), "topic", 3, nil))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, uint64(1), d.Stats().Dropped)
+}
+
+func TestDispatcher_CountsFailuresAndReportsThem(t *testing.T) {
+	p := newGatedProducer()
+	p.err = errors.New("broker down")
+	close(p.release)
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{Workers: 1})
+
+	errCh := make(chan error, 1)
+	d.Enqueue(context.Background(), "topic", 1, func(ctx context.Context, err error) { errCh <- err })
+
+	select {
+	case err := <-errCh:
+		assert.EqualError(t, err, "broker down")
+	case <-time.After(2 * time.Second):
+		t.Fatal("onErr was not called")
+	}
+	assert.Equal(t, uint64(1), d.Stats().Failed)
+	assert.Zero(t, d.Stats().Sent)
+}
+
+func TestDispatcher_DetachesFromRequestCancellation(t *testing.T) {
+	p := newGatedProducer()
+	close(p.release)
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{Workers: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // the HTTP request has already finished
+	d.Enqueue(ctx, "topic", 1, nil)
+
+	waitFor(t, func() bool { return d.Stats().Sent == 1 })
+}
+
+func TestDispatcher_FlushWaitsForPending(t *testing.T) {
+	p := newGatedProducer()
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{Workers: 1})
+	d.Enqueue(context.Background(), "topic", 1, nil)
+	d.Enqueue(context.Background(), "topic", 2, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, d.Flush(ctx), context.DeadlineExceeded)
+
+	close(p.release)
+	assert.NoError(t, d.Flush(context.Background()))
+	assert.Equal(t, uint64(2), d.Stats().Sent)
+}
+
+func TestDispatcher_ShutdownRejectsNewEvents(t *testing.T) {
+	p := newGatedProducer()
+	close(p.release)
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{})
+
+	assert.False(t, d.Closed())
+	abandoned, err := d.Shutdown(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, abandoned)
+	assert.True(t, d.Closed())
+	assert.False(t, d.Enqueue(context.Background(), "topic", 1, nil))
+	assert.Equal(t, uint64(1), d.Stats().Dropped)
+}
+
+func TestDispatcher_ShutdownReportsAbandoned(t *testing.T) {
+	p := newGatedProducer()
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{Workers: 1, DeadLetterTopic: "dlq"})
+	var reported atomic.Int32
+	for i := 0; i < 3; i++ {
+		d.Enqueue(context.Background(), "topic", i, func(context.Context, error) { reported.Add(1) })
+	}
+	p.awaitBusy(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	abandoned, err := d.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 3, abandoned) // one in flight, two queued
+
+	// The aborted in-flight send is abandoned only: not failed, reported or
+	// dead-lettered.
+	time.Sleep(20 * time.Millisecond)
+	stats := d.Stats()
+	assert.Equal(t, uint64(3), stats.Abandoned)
+	assert.Zero(t, stats.Failed)
+	assert.Zero(t, stats.DeadLettered)
+	assert.Zero(t, reported.Load())
+	assert.Len(t, p.entered, 0, "no dead-letter send attempted")
+}
+
+func TestDispatcher_ShutdownTwiceAfterTimeout(t *testing.T) {
+	p := newGatedProducer()
+	d := adapters.NewDispatcher(p, adapters.DispatcherConfig{Workers: 1, QueueSize: 64})
+	const n = 50
+	for i := 0; i < n; i++ {
+		require.True(t, d.Enqueue(context.Background(), "topic", i, nil))
+	}
+	p.awaitBusy(t)
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		abandoned, err := d.Shutdown(ctx)
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, n, abandoned)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = d.Shutdown(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("repeat Shutdown blocked")
+	}
+	assert.Equal(t, uint64(n), d.Stats().Abandoned)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, d.Flush(ctx), "discarded events are no longer pending")
+}
+
+func TestProducerAdapter_ShutdownDrainsThenCloses(t *testing.T) {
+	p := newGatedProducer()
+	a := newAdapter(t, p)
+	a.Enqueue(context.Background(), "topic", 1, nil)
+	a.Enqueue(context.Background(), "topic", 2, nil)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		assert.False(t, p.isClosed(), "producer closed before pending events drained")
+		close(p.release)
+	}()
+
+	abandoned, err := a.Shutdown(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, abandoned)
+	assert.ElementsMatch(t, []any{1, 2}, p.sent())
+	assert.True(t, p.isClosed())
+}
@@ -0,0 +1,158 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"sync"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// FanOutRoute sends events whose type matches Pattern to every sink in Sinks.
+// Pattern uses path.Match syntax on the dot-separated event type, so "authz.*"
+// matches "authz.denied" and "*" matches everything.
+type FanOutRoute struct {
+	Pattern string
+	Sinks   []interfaces.Producer
+}
+
+// FanOut is an interfaces.Producer that routes each event, by its
+// sdkmodels.EventJson EventType, to one or more sinks. Every matching route
+// contributes its sinks; a pointer sink listed under several matching routes
+// receives the event once. Values that are not an EventJson have an empty event type and
+// only match "*".
+//
+// Sinks are isolated from each other: they are called concurrently, a panic in
+// one is recovered, and Send fails only if every matched sink failed (so an
+// upstream retry never duplicates events on healthy sinks). Partial failures
+// are reported to OnError.
+type FanOut struct {
+	routes []FanOutRoute
+
+	// OnError, if set, receives each individual sink failure.
+	OnError func(ctx context.Context, sink interfaces.Producer, err error)
+}
+
+var _ interfaces.MessageProducer = (*FanOut)(nil)
+
+// NewFanOut validates the route patterns.
+func NewFanOut(routes ...FanOutRoute) (*FanOut, error) {
+	for _, r := range routes {
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("adapters: invalid fan-out pattern %q: %w", r.Pattern, err)
+		}
+	}
+	return &FanOut{routes: routes}, nil
+}
+
+// Send delivers value to every sink routed for its event type. An event that
+// matches no route is discarded.
+func (f *FanOut) Send(ctx context.Context, topic string, value any) error {
+	return f.SendMessage(ctx, topic, interfaces.Message{Value: value})
+}
+
+// SendMessage is Send for a message with a key and headers, which are passed
+// on to sinks implementing interfaces.MessageProducer.
+func (f *FanOut) SendMessage(ctx context.Context, topic string, msg interfaces.Message) error {
+	sinks := f.match(eventTypeOf(msg.Value))
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(sinks))
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = sendIsolated(ctx, sink, topic, msg)
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		failed++
+		if f.OnError != nil {
+			f.OnError(ctx, sinks[i], err)
+		}
+	}
+	if failed == len(sinks) {
+		return fmt.Errorf("adapters: all %d fan-out sinks failed: %w", failed, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Close closes every distinct sink.
+func (f *FanOut) Close() error {
+	var errs []error
+	for _, s := range f.collect(func(FanOutRoute) bool { return true }) {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// match returns the distinct sinks of every route matching eventType.
+func (f *FanOut) match(eventType string) []interfaces.Producer {
+	return f.collect(func(r FanOutRoute) bool {
+		ok, _ := path.Match(r.Pattern, eventType)
+		return ok
+	})
+}
+
+// collect returns the sinks of the included routes, deduplicated by pointer
+// identity. Sinks that are not pointers cannot be told apart safely (comparing
+// a struct holding a slice panics), so each listing of one counts.
+func (f *FanOut) collect(include func(FanOutRoute) bool) []interfaces.Producer {
+	type identity struct {
+		typ reflect.Type
+		ptr uintptr
+	}
+	var out []interfaces.Producer
+	seen := map[identity]bool{}
+	for _, r := range f.routes {
+		if !include(r) {
+			continue
+		}
+		for _, s := range r.Sinks {
+			if v := reflect.ValueOf(s); v.Kind() == reflect.Pointer {
+				id := identity{v.Type(), v.Pointer()}
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+			}
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func sendIsolated(ctx context.Context, sink interfaces.Producer, topic string, msg interfaces.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("adapters: sink %T panicked: %v", sink, r)
+		}
+	}()
+	if err := sendMessage(ctx, sink, topic, msg); err != nil {
+		return fmt.Errorf("adapters: sink %T: %w", sink, err)
+	}
+	return nil
+}
+
+func eventTypeOf(value any) string {
+	switch ev := value.(type) {
+	case sdkmodels.EventJson:
+		return ev.EventType
+	case CloudEvent:
+		return ev.Type
+	}
+	return ""
+}
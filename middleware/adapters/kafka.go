@@ -2,38 +2,341 @@ package adapters
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
 
 	dskafka "github.com/grasp-labs/ds-event-stream-go-sdk/dskafka"
 	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
 )
 
+const (
+	// defaultCloseTimeout bounds how long Close waits for pending events.
+	defaultCloseTimeout = 5 * time.Second
+	// kafkaWriteTimeout bounds a KafkaProducerWrapper write whose context has
+	// no deadline, as the SDK does.
+	kafkaWriteTimeout = 10 * time.Second
+)
+
+// ProducerAdapter is the shared event path used by every middleware. Events are
+// handed to a bounded Dispatcher (see Enqueue); Send remains a synchronous
+// pass-through for callers that want the producer's error directly.
+//
+// A struct literal (&ProducerAdapter{Producer: p}) is valid and uses the
+// default DispatcherConfig; use NewProducerAdapter to tune it.
 type ProducerAdapter struct {
 	Producer interfaces.Producer
+
+	dispatchCfg DispatcherConfig
+	router      *TopicRouter
+	key         PartitionKey
+	once        sync.Once
+	dispatcher  *Dispatcher
+	closeOnce   sync.Once
+	closeErr    error
+}
+
+// ProducerOption configures a ProducerAdapter.
+type ProducerOption func(*ProducerAdapter)
+
+// WithDispatcherConfig sets the worker pool, queue size, overflow policy and
+// send timeout of the adapter's Dispatcher. Apply it before WithRetryPolicy and
+// WithDeadLetterTopic, or set Retry/DeadLetterTopic on cfg directly.
+func WithDispatcherConfig(cfg DispatcherConfig) ProducerOption {
+	return func(a *ProducerAdapter) { a.dispatchCfg = cfg }
+}
+
+// WithRetryPolicy retries failed sends with exponential backoff and jitter.
+func WithRetryPolicy(p RetryPolicy) ProducerOption {
+	return func(a *ProducerAdapter) { a.dispatchCfg.Retry = p }
+}
+
+// WithDeadLetterTopic routes events whose retries are exhausted to topic. An
+// sdkmodels.EventJson is forwarded unchanged apart from dead_letter.* entries
+// in its Metadata; any other value is wrapped in a DeadLetter.
+func WithDeadLetterTopic(topic string) ProducerOption {
+	return func(a *ProducerAdapter) { a.dispatchCfg.DeadLetterTopic = topic }
+}
+
+// WithTopicRouter routes events by type (and optionally tenant) to topics. The
+// topic each middleware was constructed with becomes the fallback for events no
+// route matches.
+func WithTopicRouter(r *TopicRouter) ProducerOption {
+	return func(a *ProducerAdapter) { a.router = r }
+}
+
+// WithPartitionKey sets which event field becomes the message key (default:
+// none). It only takes effect with producers implementing
+// interfaces.MessageProducer. NewProducerAdapter rejects it for a
+// KafkaProducerWrapper without Writer, whose SDK producer keys by event Id.
+func WithPartitionKey(k PartitionKey) ProducerOption {
+	return func(a *ProducerAdapter) { a.key = k }
+}
+
+// WithCloudEvents encodes every sdkmodels.EventJson the adapter sends as a
+// CloudEvent (see CloudEventsProducer). To choose the encoding per sink, wrap
+// the sinks with NewCloudEventsProducer instead.
+func WithCloudEvents(mode CloudEventsMode) ProducerOption {
+	return func(a *ProducerAdapter) { a.Producer = NewCloudEventsProducer(a.Producer, mode) }
+}
+
+// NewProducerAdapter wraps producer with the given options. It fails if a
+// PartitionKey is set but producer would drop it (see WithPartitionKey).
+func NewProducerAdapter(producer interfaces.Producer, opts ...ProducerOption) (*ProducerAdapter, error) {
+	a := &ProducerAdapter{Producer: producer}
+	for _, o := range opts {
+		o(a)
+	}
+	if kd, ok := a.Producer.(keyDropper); ok && a.key != KeyDefault && kd.dropsKeys() {
+		return nil, errors.New("adapters: WithPartitionKey needs KafkaProducerWrapper.Writer; the ds-event-stream producer keys messages by event Id")
+	}
+	return a, nil
+}
+
+// keyDropper is implemented by producers that may ignore message keys.
+type keyDropper interface {
+	dropsKeys() bool
 }
 
+// Send synchronously sends value to the routed topic (see WithTopicRouter),
+// with the same key and headers as Enqueue.
 func (a *ProducerAdapter) Send(ctx context.Context, topic string, value any) error {
-	return a.Producer.Send(ctx, topic, value)
+	return sendMessage(ctx, a.Producer, a.router.route(value, topic), newMessage(ctx, value, a.key))
+}
+
+// Enqueue hands value to the adapter's Dispatcher without blocking the caller
+// beyond the configured overflow policy. onErr is invoked from a worker if the
+// producer rejects the event. Returns false if the event was dropped. topic is
+// replaced by the routed topic when a TopicRouter is configured. Events carry
+// the headers listed with HeaderRequestID and the configured PartitionKey.
+func (a *ProducerAdapter) Enqueue(ctx context.Context, topic string, value any, onErr func(ctx context.Context, err error)) bool {
+	return a.Dispatcher().EnqueueMessage(ctx, a.router.route(value, topic), newMessage(ctx, value, a.key), onErr)
+}
+
+// Dispatcher returns the adapter's Dispatcher, starting it on first use.
+func (a *ProducerAdapter) Dispatcher() *Dispatcher {
+	a.once.Do(func() {
+		a.dispatcher = NewDispatcher(a.Producer, a.dispatchCfg)
+	})
+	return a.dispatcher
+}
+
+// Closed reports whether the adapter has been shut down.
+func (a *ProducerAdapter) Closed() bool {
+	return a.Dispatcher().Closed()
+}
+
+// Stats returns the Dispatcher counters.
+func (a *ProducerAdapter) Stats() DispatcherStats {
+	return a.Dispatcher().Stats()
+}
+
+// Flush waits until every event handed to Enqueue so far has been sent or has
+// failed, or until ctx is done.
+func (a *ProducerAdapter) Flush(ctx context.Context) error {
+	return a.Dispatcher().Flush(ctx)
+}
+
+// Shutdown drains the adapter for process exit (e.g. on SIGTERM): it stops
+// accepting events, waits for pending ones until ctx is done, and only then
+// closes the underlying Producer. It returns the number of events abandoned
+// because ctx expired first; the error joins ctx.Err() with any Close error.
+// Calling Shutdown again closes nothing twice.
+func (a *ProducerAdapter) Shutdown(ctx context.Context) (int, error) {
+	abandoned, err := a.Dispatcher().Shutdown(ctx)
+	if err != nil {
+		err = fmt.Errorf("adapters: %d events abandoned: %w", abandoned, err)
+	}
+	a.closeOnce.Do(func() { a.closeErr = a.Producer.Close() })
+	return abandoned, errors.Join(err, a.closeErr)
 }
 
+// Close is Shutdown bounded by defaultCloseTimeout.
 func (a *ProducerAdapter) Close() error {
-	return a.Producer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCloseTimeout)
+	defer cancel()
+	_, err := a.Shutdown(ctx)
+	return err
 }
 
-// KafkaProducerWrapper implements interfaces.Producer for the real Kafka producer
+// KafkaWriter is the part of *kafka.Writer that KafkaProducerWrapper writes
+// through.
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaProducerWrapper implements interfaces.Producer for the real Kafka
+// producer. Set Writer to write messages directly, with the ProducerAdapter's
+// PartitionKey as the Kafka message key; with only Producer set, the
+// ds-event-stream SDK keys every message by event Id, so NewProducerAdapter
+// rejects a PartitionKey. CloudEvents (WithCloudEvents, CloudEventsProducer) also need Writer:
+// the SDK only sends sdkmodels.EventJson.
 type KafkaProducerWrapper struct {
 	Producer *dskafka.Producer
+	Writer   KafkaWriter
 }
 
+var _ interfaces.MessageProducer = (*KafkaProducerWrapper)(nil)
+
+// dropsKeys reports whether messages go through the SDK producer, which keys
+// by event Id.
+func (w *KafkaProducerWrapper) dropsKeys() bool { return w.Writer == nil }
+
 func (w *KafkaProducerWrapper) Send(ctx context.Context, topic string, value any) error {
-	event, ok := value.(sdkmodels.EventJson)
-	if !ok {
-		return fmt.Errorf("KafkaProducerWrapper: value is not sdkmodels.EventJson")
+	return w.SendMessage(ctx, topic, interfaces.Message{Value: value})
+}
+
+// SendMessage sends msg.Value with msg.Headers as Kafka headers, after the
+// SDK's content-type and message-type ones; a content-type in msg.Headers
+// (application/cloudevents+json for structured CloudEvents) replaces the
+// default application/json. Through Writer any JSON value is accepted and the
+// message key is msg.Key or, when that is empty, the key the SDK would choose:
+// the event Id (SessionId for a nil Id), or the CloudEvent id.
+func (w *KafkaProducerWrapper) SendMessage(ctx context.Context, topic string, msg interfaces.Message) error {
+	keys := slices.DeleteFunc(slices.Sorted(maps.Keys(msg.Headers)), func(k string) bool { return k == HeaderContentType })
+	if w.Writer == nil {
+		event, ok := msg.Value.(sdkmodels.EventJson)
+		if !ok {
+			return fmt.Errorf("KafkaProducerWrapper: value is not sdkmodels.EventJson (set Writer to send other values)")
+		}
+		headers := make([]dskafka.Header, 0, len(keys))
+		for _, k := range keys {
+			headers = append(headers, dskafka.Header{Key: k, Value: msg.Headers[k]})
+		}
+		return w.Producer.SendEvent(ctx, topic, event, headers...)
+	}
+
+	value, err := json.Marshal(msg.Value)
+	if err != nil {
+		return fmt.Errorf("KafkaProducerWrapper: %w", err)
+	}
+	key := msg.Key
+	if key == "" {
+		key = defaultMessageKey(msg)
 	}
-	return w.Producer.SendEvent(ctx, topic, event)
+	contentType := msg.Headers[HeaderContentType]
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	headers := make([]kafka.Header, 0, len(keys)+2)
+	headers = append(headers,
+		kafka.Header{Key: HeaderContentType, Value: []byte(contentType)},
+		kafka.Header{Key: "message-type", Value: []byte("Event")},
+	)
+	for _, k := range keys {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(msg.Headers[k])})
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, kafkaWriteTimeout)
+		defer cancel()
+	}
+	out := kafka.Message{Topic: topic, Value: value, Headers: headers, Time: time.Now()}
+	if key != "" {
+		out.Key = []byte(key)
+	}
+	return w.Writer.WriteMessages(ctx, out)
 }
 
 func (w *KafkaProducerWrapper) Close() error {
+	if w.Writer != nil {
+		return errors.Join(w.Writer.Close(), w.Producer.Close())
+	}
 	return w.Producer.Close()
 }
+
+// defaultMessageKey is the key for a message without one: the event Id
+// (SessionId for a nil Id) as the SDK chooses it, or the CloudEvent id in
+// either encoding.
+func defaultMessageKey(msg interfaces.Message) string {
+	switch v := msg.Value.(type) {
+	case sdkmodels.EventJson:
+		if v.Id == uuid.Nil {
+			return v.SessionId.String()
+		}
+		return v.Id.String()
+	case CloudEvent:
+		return v.ID
+	}
+	return msg.Headers[cloudEventsBinaryHeaderPrefix+"id"]
+}
+
+// KafkaEventReader is the part of *dskafka.Consumer that KafkaConsumerWrapper
+// reads through.
+type KafkaEventReader interface {
+	ReadEventWithMessage(ctx context.Context, topic string, groupID ...string) (*sdkmodels.EventJson, kafka.Message, error)
+	CommitEvent(ctx context.Context, message kafka.Message) error
+	Close() error
+}
+
+var _ KafkaEventReader = (*dskafka.Consumer)(nil)
+
+// KafkaConsumerWrapper implements interfaces.Consumer for the real Kafka
+// consumer, e.g. for middleware.ConsumeRevocations. Receive returns the
+// decoded sdkmodels.EventJson as the Value, with the record's key and headers,
+// and commits the previous record first; Close commits the last one. The SDK's
+// per-read timeout is retried until ctx is done.
+type KafkaConsumerWrapper struct {
+	Consumer KafkaEventReader
+
+	mu      sync.Mutex
+	pending *kafka.Message // read but not yet committed
+}
+
+var _ interfaces.Consumer = (*KafkaConsumerWrapper)(nil)
+
+func (w *KafkaConsumerWrapper) Receive(ctx context.Context, topic string) (interfaces.Message, error) {
+	w.mu.Lock()
+	err := w.commitPending(ctx)
+	w.mu.Unlock()
+	if err != nil {
+		return interfaces.Message{}, err
+	}
+	for {
+		ev, record, err := w.Consumer.ReadEventWithMessage(ctx, topic)
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				continue
+			}
+			return interfaces.Message{}, err
+		}
+		w.mu.Lock()
+		w.pending = &record
+		w.mu.Unlock()
+		msg := interfaces.Message{Key: string(record.Key), Headers: make(map[string]string, len(record.Headers)), Value: *ev}
+		for _, h := range record.Headers {
+			msg.Headers[h.Key] = string(h.Value)
+		}
+		return msg, nil
+	}
+}
+
+func (w *KafkaConsumerWrapper) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCloseTimeout)
+	defer cancel()
+	return errors.Join(w.commitPending(ctx), w.Consumer.Close())
+}
+
+// commitPending commits the record handed out by the last Receive.
+func (w *KafkaConsumerWrapper) commitPending(ctx context.Context) error {
+	if w.pending == nil {
+		return nil
+	}
+	if err := w.Consumer.CommitEvent(ctx, *w.pending); err != nil {
+		return fmt.Errorf("KafkaConsumerWrapper: commit: %w", err)
+	}
+	w.pending = nil
+	return nil
+}
@@ -0,0 +1,215 @@
+package adapters_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// kafkaWriter records the messages KafkaProducerWrapper writes.
+type kafkaWriter struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+}
+
+func (w *kafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *kafkaWriter) Close() error { return nil }
+
+func (w *kafkaWriter) messages() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.msgs...)
+}
+
+func headerMap(hs []kafka.Header) map[string]string {
+	m := map[string]string{}
+	for _, h := range hs {
+		m[h.Key] = string(h.Value)
+	}
+	return m
+}
+
+func TestKafkaProducerWrapper_WritesPartitionKey(t *testing.T) {
+	w := &kafkaWriter{}
+	a := newAdapter(t, &adapters.KafkaProducerWrapper{Writer: w}, adapters.WithPartitionKey(adapters.KeyTenantID))
+	ev := sdkmodels.EventJson{Id: uuid.New(), TenantId: uuid.New(), EventType: "login.success"}
+
+	require.NoError(t, a.Send(context.Background(), "events", ev))
+
+	msgs := w.messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "events", msgs[0].Topic)
+	assert.Equal(t, ev.TenantId.String(), string(msgs[0].Key))
+	headers := headerMap(msgs[0].Headers)
+	assert.Equal(t, "application/json", headers["content-type"])
+	assert.Equal(t, ev.TenantId.String(), headers[adapters.HeaderTenantID])
+	assert.Equal(t, "login.success", headers[adapters.HeaderEventType])
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(msgs[0].Value, &got))
+	assert.Equal(t, ev.Id.String(), got["id"])
+}
+
+func TestKafkaProducerWrapper_DefaultKeyIsEventID(t *testing.T) {
+	w := &kafkaWriter{}
+	a := newAdapter(t, &adapters.KafkaProducerWrapper{Writer: w})
+	ev := sdkmodels.EventJson{Id: uuid.New(), TenantId: uuid.New()}
+	noID := sdkmodels.EventJson{SessionId: uuid.New()}
+
+	require.NoError(t, a.Send(context.Background(), "events", ev))
+	require.NoError(t, a.Send(context.Background(), "events", noID))
+
+	msgs := w.messages()
+	require.Len(t, msgs, 2)
+	assert.Equal(t, ev.Id.String(), string(msgs[0].Key))
+	assert.Equal(t, noID.SessionId.String(), string(msgs[1].Key))
+}
+
+func TestKafkaProducerWrapper_WithCloudEvents(t *testing.T) {
+	ev := cloudEventsFixture()
+	cases := []struct {
+		mode        adapters.CloudEventsMode
+		contentType string
+		value       string
+	}{
+		{adapters.CloudEventsStructured, adapters.CloudEventsContentType, `"specversion":"1.0"`},
+		{adapters.CloudEventsBinary, "application/json", `{"path":"/x","subject":"user@email.com"}`},
+	}
+	for _, tc := range cases {
+		w := &kafkaWriter{}
+		a := newAdapter(t, &adapters.KafkaProducerWrapper{Writer: w}, adapters.WithCloudEvents(tc.mode))
+
+		require.NoError(t, a.Send(context.Background(), "events", ev))
+
+		msgs := w.messages()
+		require.Len(t, msgs, 1)
+		assert.Equal(t, ev.Id.String(), string(msgs[0].Key))
+		assert.Contains(t, string(msgs[0].Value), tc.value)
+		var contentTypes []string
+		for _, h := range msgs[0].Headers {
+			if h.Key == adapters.HeaderContentType {
+				contentTypes = append(contentTypes, string(h.Value))
+			}
+		}
+		assert.Equal(t, []string{tc.contentType}, contentTypes, "one content-type header")
+		if tc.mode == adapters.CloudEventsBinary {
+			headers := headerMap(msgs[0].Headers)
+			assert.Equal(t, ev.Id.String(), headers["ce_id"])
+			assert.Equal(t, "login.success", headers["ce_type"])
+		}
+	}
+}
+
+func TestKafkaProducerWrapper_SDKProducerRejectsCloudEvents(t *testing.T) {
+	a := newAdapter(t, &adapters.KafkaProducerWrapper{}, adapters.WithCloudEvents(adapters.CloudEventsStructured))
+	assert.ErrorContains(t, a.Send(context.Background(), "events", cloudEventsFixture()), "set Writer")
+}
+
+func TestNewProducerAdapter_RejectsPartitionKeyWithoutWriter(t *testing.T) {
+	sdk := &adapters.KafkaProducerWrapper{}
+	_, err := adapters.NewProducerAdapter(sdk, adapters.WithPartitionKey(adapters.KeyTenantID))
+	assert.ErrorContains(t, err, "Writer")
+	_, err = adapters.NewProducerAdapter(sdk, adapters.WithCloudEvents(adapters.CloudEventsBinary), adapters.WithPartitionKey(adapters.KeyTenantID))
+	assert.Error(t, err, "also when wrapped")
+	_, err = adapters.NewProducerAdapter(sdk)
+	assert.NoError(t, err, "the SDK's own key is fine without a PartitionKey")
+}
+
+// eventReader hands out queued records and records commits; an empty queue
+// times out like the SDK's per-read deadline.
+type eventReader struct {
+	mu        sync.Mutex
+	records   []kafka.Message
+	committed []int64
+	closed    bool
+}
+
+func (r *eventReader) ReadEventWithMessage(ctx context.Context, topic string, _ ...string) (*sdkmodels.EventJson, kafka.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) == 0 {
+		return nil, kafka.Message{}, context.DeadlineExceeded
+	}
+	rec := r.records[0]
+	r.records = r.records[1:]
+	var ev sdkmodels.EventJson
+	if err := json.Unmarshal(rec.Value, &ev); err != nil {
+		return nil, kafka.Message{}, err
+	}
+	return &ev, rec, nil
+}
+
+func (r *eventReader) CommitEvent(ctx context.Context, m kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = append(r.committed, m.Offset)
+	return nil
+}
+
+func (r *eventReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *eventReader) commits() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64(nil), r.committed...)
+}
+
+func TestKafkaConsumerWrapper_ReceivesAndCommits(t *testing.T) {
+	w := &kafkaWriter{}
+	p := &adapters.KafkaProducerWrapper{Writer: w}
+	ev := cloudEventsFixture()
+	ev.Md5Hash = "0123456789abcdef0123456789abcdef"
+	require.NoError(t, p.SendMessage(context.Background(), "revocations", interfaces.Message{
+		Key: "k", Headers: map[string]string{adapters.HeaderEventType: ev.EventType}, Value: ev,
+	}))
+	records := w.messages()
+	records = append(records, records[0])
+	records[0].Offset, records[1].Offset = 1, 2
+
+	r := &eventReader{records: records}
+	c := &adapters.KafkaConsumerWrapper{Consumer: r}
+
+	msg, err := c.Receive(context.Background(), "revocations")
+	require.NoError(t, err)
+	assert.Equal(t, "k", msg.Key)
+	assert.Equal(t, ev.EventType, msg.Headers[adapters.HeaderEventType])
+	got, ok := msg.Value.(sdkmodels.EventJson)
+	require.True(t, ok)
+	assert.Equal(t, ev.Id, got.Id)
+	assert.Empty(t, r.commits(), "a record is committed once the next one is requested")
+
+	_, err = c.Receive(context.Background(), "revocations")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, r.commits())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.Receive(ctx, "revocations")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "read timeouts are retried until ctx is done")
+	assert.Equal(t, []int64{1, 2}, r.commits())
+
+	require.NoError(t, c.Close())
+	assert.True(t, r.closed)
+}
@@ -0,0 +1,91 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
+)
+
+// Headers attached to every sdkmodels.EventJson sent through a ProducerAdapter,
+// so consumers can route without deserializing the payload. Empty values are
+// omitted.
+const (
+	HeaderRequestID          = "request_id"
+	HeaderSessionID          = "session_id"
+	HeaderTenantID           = "tenant_id"
+	HeaderTraceparent        = "traceparent"
+	HeaderEventType          = "event_type"
+	HeaderServicePrincipalID = "service_principal_id"
+)
+
+// PartitionKey selects which event field becomes the message key.
+type PartitionKey int
+
+const (
+	// KeyDefault sets no key and leaves partitioning to the producer.
+	KeyDefault PartitionKey = iota
+	// KeyTenantID keeps each tenant's events in order on one partition.
+	KeyTenantID
+	// KeySessionID keeps each session's events in order on one partition.
+	KeySessionID
+	// KeyRequestID keeps each request's events in order on one partition.
+	KeyRequestID
+)
+
+// newMessage wraps value with its key and headers. Only sdkmodels.EventJson
+// values carry event fields; traceparent comes from the request context.
+func newMessage(ctx context.Context, value any, key PartitionKey) interfaces.Message {
+	msg := interfaces.Message{Value: value, Headers: map[string]string{}}
+	if tp := requestctx.GetTraceparent(ctx); tp != "" {
+		msg.Headers[HeaderTraceparent] = tp
+	}
+
+	ev, ok := value.(sdkmodels.EventJson)
+	if !ok {
+		return msg
+	}
+	setIDHeader(msg.Headers, HeaderRequestID, ev.RequestId)
+	setIDHeader(msg.Headers, HeaderSessionID, ev.SessionId)
+	setIDHeader(msg.Headers, HeaderTenantID, ev.TenantId)
+	if ev.EventType != "" {
+		msg.Headers[HeaderEventType] = ev.EventType
+	}
+	if ev.EventSource != "" {
+		msg.Headers[HeaderServicePrincipalID] = ev.EventSource
+	}
+
+	switch key {
+	case KeyTenantID:
+		msg.Key = idString(ev.TenantId)
+	case KeySessionID:
+		msg.Key = idString(ev.SessionId)
+	case KeyRequestID:
+		msg.Key = idString(ev.RequestId)
+	}
+	return msg
+}
+
+func setIDHeader(h map[string]string, name string, id uuid.UUID) {
+	if s := idString(id); s != "" {
+		h[name] = s
+	}
+}
+
+func idString(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
+// sendMessage uses the producer's SendMessage when it has one, else Send.
+func sendMessage(ctx context.Context, p interfaces.Producer, topic string, msg interfaces.Message) error {
+	if mp, ok := p.(interfaces.MessageProducer); ok {
+		return mp.SendMessage(ctx, topic, msg)
+	}
+	return p.Send(ctx, topic, msg.Value)
+}
@@ -0,0 +1,149 @@
+package adapters_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
+)
+
+// newAdapter is NewProducerAdapter for options that must be accepted.
+func newAdapter(t *testing.T, p interfaces.Producer, opts ...adapters.ProducerOption) *adapters.ProducerAdapter {
+	t.Helper()
+	a, err := adapters.NewProducerAdapter(p, opts...)
+	require.NoError(t, err)
+	return a
+}
+
+// messageProducer records what it receives through SendMessage, and fails
+// while down is set.
+type messageProducer struct {
+	mu   sync.Mutex
+	down bool
+	msgs []interfaces.Message
+}
+
+func (p *messageProducer) setDown(down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = down
+}
+
+func (p *messageProducer) Send(ctx context.Context, topic string, value any) error {
+	return p.SendMessage(ctx, topic, interfaces.Message{Value: value})
+}
+
+func (p *messageProducer) SendMessage(ctx context.Context, topic string, msg interfaces.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		return errors.New("broker unavailable")
+	}
+	p.msgs = append(p.msgs, msg)
+	return nil
+}
+
+func (p *messageProducer) Close() error { return nil }
+
+func (p *messageProducer) messages() []interfaces.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]interfaces.Message(nil), p.msgs...)
+}
+
+func TestProducerAdapter_Enqueue_AttachesKeyAndHeaders(t *testing.T) {
+	mp := &messageProducer{}
+	adapter := newAdapter(t, mp, adapters.WithPartitionKey(adapters.KeyTenantID))
+	t.Cleanup(func() { _ = adapter.Close() })
+
+	ev := sdkmodels.EventJson{
+		Id:          uuid.New(),
+		RequestId:   uuid.New(),
+		SessionId:   uuid.New(),
+		TenantId:    uuid.New(),
+		EventType:   "authz.denied",
+		EventSource: "svc-principal",
+	}
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := requestctx.SetTraceparent(context.Background(), tp)
+
+	require.True(t, adapter.Enqueue(ctx, "events", ev, nil))
+	require.NoError(t, adapter.Flush(context.Background()))
+
+	msgs := mp.messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, ev.TenantId.String(), msgs[0].Key)
+	assert.Equal(t, map[string]string{
+		adapters.HeaderRequestID:          ev.RequestId.String(),
+		adapters.HeaderSessionID:          ev.SessionId.String(),
+		adapters.HeaderTenantID:           ev.TenantId.String(),
+		adapters.HeaderTraceparent:        tp,
+		adapters.HeaderEventType:          "authz.denied",
+		adapters.HeaderServicePrincipalID: "svc-principal",
+	}, msgs[0].Headers)
+	assert.Equal(t, ev, msgs[0].Value)
+}
+
+func TestProducerAdapter_Send_OmitsEmptyHeaders(t *testing.T) {
+	mp := &messageProducer{}
+	adapter := newAdapter(t, mp, adapters.WithPartitionKey(adapters.KeySessionID))
+
+	require.NoError(t, adapter.Send(context.Background(), "events", sdkmodels.EventJson{EventType: "usage"}))
+
+	msgs := mp.messages()
+	require.Len(t, msgs, 1)
+	assert.Empty(t, msgs[0].Key)
+	assert.Equal(t, map[string]string{adapters.HeaderEventType: "usage"}, msgs[0].Headers)
+}
+
+func TestProducerAdapter_PlainProducer_ReceivesValue(t *testing.T) {
+	fp := &flakyProducer{}
+	adapter := newAdapter(t, fp, adapters.WithPartitionKey(adapters.KeyRequestID))
+
+	ev := sdkmodels.EventJson{RequestId: uuid.New(), EventType: "usage"}
+	require.NoError(t, adapter.Send(context.Background(), "events", ev))
+	assert.Equal(t, []sentMessage{{topic: "events", value: ev}}, fp.messages())
+}
+
+func TestSpoolingProducer_ReplayKeepsKeyAndHeaders(t *testing.T) {
+	mp := &messageProducer{down: true}
+	sp, err := adapters.NewSpoolingProducer(mp, spoolConfig(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sp.Close() })
+
+	msg := interfaces.Message{
+		Key:     "tenant-a",
+		Headers: map[string]string{adapters.HeaderEventType: "usage"},
+		Value:   map[string]any{"n": float64(1)},
+	}
+	require.NoError(t, sp.SendMessage(context.Background(), "events", msg))
+	assert.Equal(t, 1, sp.Depth())
+	mp.setDown(false)
+
+	waitFor(t, func() bool { return len(mp.messages()) == 1 })
+	got := mp.messages()[0]
+	assert.Equal(t, msg.Key, got.Key)
+	assert.Equal(t, msg.Headers, got.Headers)
+}
+
+func TestFanOut_ForwardsKeyAndHeaders(t *testing.T) {
+	mp := &messageProducer{}
+	mem := adapters.NewMemorySink(4)
+	f, err := adapters.NewFanOut(adapters.FanOutRoute{Pattern: "*", Sinks: []interfaces.Producer{mp, mem}})
+	require.NoError(t, err)
+
+	msg := interfaces.Message{Key: "k", Headers: map[string]string{"h": "v"}, Value: event("usage")}
+	require.NoError(t, f.SendMessage(context.Background(), "events", msg))
+
+	assert.Equal(t, []interfaces.Message{msg}, mp.messages())
+	assert.Len(t, mem.Records(), 1)
+}
@@ -0,0 +1,98 @@
+package adapters
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+)
+
+// RetryPolicy controls how the Dispatcher retries a failed producer.Send. The
+// zero value makes a single attempt, which is the historical behaviour.
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts including the first; <= 1 disables retries
+	InitialBackoff time.Duration // wait before the second attempt
+	MaxBackoff     time.Duration // upper bound on any single wait (0 = unbounded)
+	Multiplier     float64       // backoff growth per attempt (<= 1 means constant)
+	Jitter         float64       // randomizes each wait by ±Jitter (0..1) of its length
+	AttemptTimeout time.Duration // bound on each attempt (0 = DispatcherConfig.SendTimeout)
+}
+
+// DefaultRetryPolicy is a reasonable policy for riding out a short broker blip:
+// up to 4 attempts over roughly a second and a half.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the wait before attempt n+1, given that attempt n (1-based)
+// has just failed.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	if p.InitialBackoff <= 0 {
+		return 0
+	}
+	d := float64(p.InitialBackoff)
+	if p.Multiplier > 1 {
+		d *= math.Pow(p.Multiplier, float64(n-1))
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if j := math.Min(math.Max(p.Jitter, 0), 1); j > 0 {
+		d *= 1 - j + 2*j*rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// Metadata keys added to an sdkmodels.EventJson routed to the dead-letter topic.
+const (
+	DeadLetterTopicKey    = "dead_letter.topic"
+	DeadLetterErrorKey    = "dead_letter.error"
+	DeadLetterAttemptsKey = "dead_letter.attempts"
+	DeadLetterFailedAtKey = "dead_letter.failed_at"
+)
+
+// DeadLetter is what the dead-letter topic receives for values that are not an
+// sdkmodels.EventJson. EventJson values are forwarded as-is instead, with the
+// same information added to their Metadata so that Kafka consumers (and
+// KafkaProducerWrapper) keep seeing the standard event shape.
+type DeadLetter struct {
+	Topic    string    `json:"topic"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+	Value    any       `json:"value"`
+}
+
+func deadLetterValue(value any, topic string, err error, attempts int, failedAt time.Time) any {
+	event, ok := value.(sdkmodels.EventJson)
+	if !ok {
+		return DeadLetter{Topic: topic, Error: err.Error(), Attempts: attempts, FailedAt: failedAt, Value: value}
+	}
+
+	// Copy so the caller's map is never mutated.
+	md := make(map[string]string, len(event.Metadata)+4)
+	for k, v := range event.Metadata {
+		md[k] = v
+	}
+	md[DeadLetterTopicKey] = topic
+	md[DeadLetterErrorKey] = err.Error()
+	md[DeadLetterAttemptsKey] = strconv.Itoa(attempts)
+	md[DeadLetterFailedAtKey] = failedAt.UTC().Format(time.RFC3339Nano)
+	event.Metadata = md
+	return event
+}
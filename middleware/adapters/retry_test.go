@@ -0,0 +1,143 @@
+package adapters_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+)
+
+type sentMessage struct {
+	topic string
+	value any
+}
+
+// flakyProducer fails the first failures sends to any topic other than
+// okTopic, then succeeds.
+type flakyProducer struct {
+	mu       sync.Mutex
+	failures int
+	okTopic  string
+	calls    int
+	sent     []sentMessage
+}
+
+func (p *flakyProducer) Send(ctx context.Context, topic string, value any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if topic != p.okTopic && p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.sent = append(p.sent, sentMessage{topic: topic, value: value})
+	return nil
+}
+
+func (p *flakyProducer) Close() error { return nil }
+
+func (p *flakyProducer) messages() []sentMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]sentMessage(nil), p.sent...)
+}
+
+func fastRetry(attempts int) adapters.RetryPolicy {
+	return adapters.RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+}
+
+func TestDispatcher_RetriesTransientFailure(t *testing.T) {
+	p := &flakyProducer{failures: 2}
+	a := newAdapter(t, p, adapters.WithRetryPolicy(fastRetry(3)))
+
+	a.Enqueue(context.Background(), "usage", "event", nil)
+	require.NoError(t, a.Flush(context.Background()))
+
+	assert.Equal(t, []sentMessage{{topic: "usage", value: "event"}}, p.messages())
+	stats := a.Stats()
+	assert.Equal(t, uint64(1), stats.Sent)
+	assert.Equal(t, uint64(2), stats.Retried)
+	assert.Zero(t, stats.Failed)
+}
+
+func TestDispatcher_DeadLettersEventJsonWithMetadata(t *testing.T) {
+	p := &flakyProducer{failures: 100, okTopic: "usage.dlq"}
+	a := newAdapter(t, p,
+		adapters.WithRetryPolicy(fastRetry(2)),
+		adapters.WithDeadLetterTopic("usage.dlq"),
+	)
+
+	event := sdkmodels.EventJson{
+		Id:        uuid.New(),
+		EventType: "usage.report",
+		Metadata:  map[string]string{"host": "pod-1"},
+	}
+	var gotErr error
+	a.Enqueue(context.Background(), "usage", event, func(ctx context.Context, err error) { gotErr = err })
+	require.NoError(t, a.Flush(context.Background()))
+
+	msgs := p.messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "usage.dlq", msgs[0].topic)
+	dl, ok := msgs[0].value.(sdkmodels.EventJson)
+	require.True(t, ok, "dead letter should keep the EventJson shape, got %T", msgs[0].value)
+	assert.Equal(t, event.Id, dl.Id)
+	assert.Equal(t, "pod-1", dl.Metadata["host"])
+	assert.Equal(t, "usage", dl.Metadata[adapters.DeadLetterTopicKey])
+	assert.Equal(t, "2", dl.Metadata[adapters.DeadLetterAttemptsKey])
+	assert.Contains(t, dl.Metadata[adapters.DeadLetterErrorKey], "broker unavailable")
+	assert.NotEmpty(t, dl.Metadata[adapters.DeadLetterFailedAtKey])
+	assert.Len(t, event.Metadata, 1, "original metadata must not be mutated")
+
+	assert.ErrorContains(t, gotErr, "after 2 attempts")
+	stats := a.Stats()
+	assert.Equal(t, uint64(1), stats.Failed)
+	assert.Equal(t, uint64(1), stats.DeadLettered)
+}
+
+func TestDispatcher_DeadLettersOtherValuesWrapped(t *testing.T) {
+	p := &flakyProducer{failures: 100, okTopic: "dlq"}
+	a := newAdapter(t, p, adapters.WithDeadLetterTopic("dlq"))
+
+	a.Enqueue(context.Background(), "audit", map[string]string{"k": "v"}, nil)
+	require.NoError(t, a.Flush(context.Background()))
+
+	msgs := p.messages()
+	require.Len(t, msgs, 1)
+	dl, ok := msgs[0].value.(adapters.DeadLetter)
+	require.True(t, ok)
+	assert.Equal(t, "audit", dl.Topic)
+	assert.Equal(t, 1, dl.Attempts)
+	assert.Equal(t, map[string]string{"k": "v"}, dl.Value)
+}
+
+func TestDispatcher_ShutdownInterruptsBackoff(t *testing.T) {
+	p := &flakyProducer{failures: 100}
+	a := newAdapter(t, p, adapters.WithRetryPolicy(adapters.RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Hour,
+	}))
+	a.Enqueue(context.Background(), "usage", "event", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	abandoned, err := a.Shutdown(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, abandoned)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,94 @@
+package adapters
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+)
+
+// TopicRoute maps event types matching Pattern to Topic. Pattern uses
+// path.Match syntax on the dot-separated event type ("authz.*", "login.failure",
+// "*"). A non-nil TenantID restricts the route to that tenant's events.
+type TopicRoute struct {
+	Pattern  string
+	Topic    string
+	TenantID uuid.UUID
+}
+
+// TopicRouter picks the destination topic of an event from its type and
+// tenant. The most specific matching route wins: a tenant route beats a global
+// one, an exact pattern beats a wildcard, and among wildcards the one with more
+// literal characters wins ("authz.*" over "*"); ties go to the route listed
+// first. When nothing matches, the topic given by the caller is kept.
+type TopicRouter struct {
+	routes []TopicRoute
+}
+
+// NewTopicRouter validates the route patterns.
+func NewTopicRouter(routes ...TopicRoute) (*TopicRouter, error) {
+	for _, r := range routes {
+		if r.Topic == "" {
+			return nil, fmt.Errorf("adapters: topic route %q has no topic", r.Pattern)
+		}
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("adapters: invalid topic route pattern %q: %w", r.Pattern, err)
+		}
+	}
+	return &TopicRouter{routes: append([]TopicRoute(nil), routes...)}, nil
+}
+
+// Resolve returns the topic for an event of eventType owned by tenantID, or
+// false if no route matches.
+func (r *TopicRouter) Resolve(eventType string, tenantID uuid.UUID) (string, bool) {
+	best, bestScore := "", -1
+	for _, route := range r.routes {
+		if route.TenantID != uuid.Nil && route.TenantID != tenantID {
+			continue
+		}
+		if ok, _ := path.Match(route.Pattern, eventType); !ok {
+			continue
+		}
+		score := routeSpecificity(route.Pattern)
+		if route.Pattern == eventType {
+			score += 1 << 20
+		}
+		if route.TenantID != uuid.Nil {
+			score += 1 << 21
+		}
+		if score > bestScore {
+			best, bestScore = route.Topic, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// routeSpecificity counts the literal (non-wildcard) characters of pattern.
+func routeSpecificity(pattern string) int {
+	n := 0
+	for _, c := range pattern {
+		if !strings.ContainsRune(`*?[]\`, c) {
+			n++
+		}
+	}
+	return n
+}
+
+// route resolves the topic for value, keeping fallback for values that are not
+// an sdkmodels.EventJson or that match no route. A nil router is a no-op.
+func (r *TopicRouter) route(value any, fallback string) string {
+	if r == nil {
+		return fallback
+	}
+	ev, ok := value.(sdkmodels.EventJson)
+	if !ok {
+		return fallback
+	}
+	if topic, ok := r.Resolve(ev.EventType, ev.TenantId); ok {
+		return topic
+	}
+	return fallback
+}
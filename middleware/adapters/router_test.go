@@ -0,0 +1,72 @@
+package adapters_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+)
+
+func TestTopicRouter_Precedence(t *testing.T) {
+	vip := uuid.New()
+	r, err := adapters.NewTopicRouter(
+		adapters.TopicRoute{Pattern: "*", Topic: "ds.events"},
+		adapters.TopicRoute{Pattern: "authz.*", Topic: "ds.authz"},
+		adapters.TopicRoute{Pattern: "authz.error", Topic: "ds.authz.errors"},
+		adapters.TopicRoute{Pattern: "authz.*", Topic: "ds.vip.authz", TenantID: vip},
+	)
+	require.NoError(t, err)
+
+	cases := []struct {
+		eventType string
+		tenant    uuid.UUID
+		want      string
+	}{
+		{"authz.denied", uuid.New(), "ds.authz"},
+		{"authz.error", uuid.New(), "ds.authz.errors"},
+		{"login.success", uuid.New(), "ds.events"},
+		{"authz.denied", vip, "ds.vip.authz"},
+		{"authz.error", vip, "ds.vip.authz"}, // tenant route beats exact global
+	}
+	for _, tc := range cases {
+		got, ok := r.Resolve(tc.eventType, tc.tenant)
+		assert.True(t, ok)
+		assert.Equal(t, tc.want, got, "%s for tenant %s", tc.eventType, tc.tenant)
+	}
+}
+
+func TestTopicRouter_NoMatch(t *testing.T) {
+	r, err := adapters.NewTopicRouter(adapters.TopicRoute{Pattern: "login.*", Topic: "ds.login"})
+	require.NoError(t, err)
+	_, ok := r.Resolve("usage.report", uuid.New())
+	assert.False(t, ok)
+}
+
+func TestTopicRouter_RejectsInvalidRoutes(t *testing.T) {
+	_, err := adapters.NewTopicRouter(adapters.TopicRoute{Pattern: "[", Topic: "x"})
+	assert.Error(t, err)
+	_, err = adapters.NewTopicRouter(adapters.TopicRoute{Pattern: "*"})
+	assert.Error(t, err)
+}
+
+func TestProducerAdapter_RoutesTopics(t *testing.T) {
+	r, err := adapters.NewTopicRouter(adapters.TopicRoute{Pattern: "login.failure", Topic: "ds.login.failures"})
+	require.NoError(t, err)
+	p := &flakyProducer{}
+	a := newAdapter(t, p, adapters.WithTopicRouter(r))
+
+	a.Enqueue(context.Background(), "ds.fallback", sdkmodels.EventJson{EventType: "login.failure"}, nil)
+	a.Enqueue(context.Background(), "ds.fallback", sdkmodels.EventJson{EventType: "login.success"}, nil)
+	require.NoError(t, a.Flush(context.Background()))
+
+	topics := map[string]bool{}
+	for _, m := range p.messages() {
+		topics[m.topic] = true
+	}
+	assert.Equal(t, map[string]bool{"ds.login.failures": true, "ds.fallback": true}, topics)
+}
@@ -0,0 +1,308 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// SinkRecord is what the built-in sinks store or emit for each Send: the
+// destination topic and the value as given.
+type SinkRecord struct {
+	Topic string `json:"topic"`
+	Value any    `json:"value"`
+}
+
+// ---------- newline-delimited JSON ----------
+
+// JSONLinesSink writes each event as one line of JSON ({"topic":…,"value":…})
+// to an io.Writer. Use it for local development (stdout) or on-prem installs
+// that ship logs from a file.
+type JSONLinesSink struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer // nil when the writer is not owned (e.g. stdout)
+}
+
+var _ interfaces.Producer = (*JSONLinesSink)(nil)
+
+// NewJSONLinesSink writes to w. Close does not close w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{enc: json.NewEncoder(w)}
+}
+
+// NewStdoutSink writes to os.Stdout.
+func NewStdoutSink() *JSONLinesSink {
+	return NewJSONLinesSink(os.Stdout)
+}
+
+// NewFileSink appends to the file at path, creating it if needed. Close closes
+// the file.
+func NewFileSink(path string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("adapters: open file sink: %w", err)
+	}
+	return &JSONLinesSink{enc: json.NewEncoder(f), closer: f}, nil
+}
+
+func (s *JSONLinesSink) Send(ctx context.Context, topic string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(SinkRecord{Topic: topic, Value: value})
+}
+
+func (s *JSONLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// ---------- in-memory ring buffer ----------
+
+// MemorySink keeps the most recent events in a fixed-size ring buffer. It is
+// meant for tests and for debug endpoints that show recent events.
+type MemorySink struct {
+	mu    sync.Mutex
+	buf   []SinkRecord
+	next  int
+	count int
+}
+
+var _ interfaces.Producer = (*MemorySink)(nil)
+
+// NewMemorySink keeps up to capacity events (minimum 1).
+func NewMemorySink(capacity int) *MemorySink {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemorySink{buf: make([]SinkRecord, capacity)}
+}
+
+func (s *MemorySink) Send(ctx context.Context, topic string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf[s.next] = SinkRecord{Topic: topic, Value: value}
+	s.next = (s.next + 1) % len(s.buf)
+	if s.count < len(s.buf) {
+		s.count++
+	}
+	return nil
+}
+
+// Records returns the buffered events, oldest first.
+func (s *MemorySink) Records() []SinkRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SinkRecord, 0, s.count)
+	start := (s.next - s.count + len(s.buf)) % len(s.buf)
+	for i := 0; i < s.count; i++ {
+		out = append(out, s.buf[(start+i)%len(s.buf)])
+	}
+	return out
+}
+
+// Reset discards all buffered events.
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.buf)
+	s.next, s.count = 0, 0
+}
+
+func (s *MemorySink) Close() error { return nil }
+
+// ---------- HTTP webhook ----------
+
+// Webhook defaults.
+const (
+	defaultWebhookBatchSize     = 50
+	defaultWebhookFlushInterval = time.Second
+	defaultWebhookTimeout       = 5 * time.Second
+)
+
+// ErrWebhookBacklog is returned by WebhookSink.Send when undelivered batches
+// have reached WebhookConfig.MaxPending.
+var ErrWebhookBacklog = errors.New("adapters: webhook backlog is full")
+
+// WebhookConfig configures a WebhookSink. URL is required; other zero values
+// fall back to the defaults.
+type WebhookConfig struct {
+	URL           string
+	Client        *http.Client      // default: 5s timeout
+	Headers       map[string]string // added to every request (e.g. Authorization)
+	BatchSize     int               // events per POST
+	FlushInterval time.Duration     // max time an event waits for its batch to fill
+	MaxPending    int               // cap on undelivered events (default 4 batches)
+}
+
+// WebhookSink POSTs events as a JSON array of SinkRecord to an HTTP endpoint,
+// batching up to BatchSize events or FlushInterval, whichever comes first. A
+// batch that fails to post is kept and retried with the next flush. Posts run
+// in the background, so a slow endpoint never blocks Send.
+type WebhookSink struct {
+	cfg WebhookConfig
+
+	flushMu sync.Mutex // serializes posts, so batches go out in order
+
+	mu       sync.Mutex // guards the fields below; never held during a post
+	pending  []SinkRecord
+	inflight int // events in the batch being posted
+	lastErr  error
+
+	kick      chan struct{} // asks the loop to flush a full batch
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+var _ interfaces.Producer = (*WebhookSink)(nil)
+
+// NewWebhookSink starts the sink's periodic flush.
+func NewWebhookSink(cfg WebhookConfig) (*WebhookSink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("adapters: webhook URL is required")
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultWebhookBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultWebhookFlushInterval
+	}
+	if cfg.MaxPending < cfg.BatchSize {
+		cfg.MaxPending = 4 * cfg.BatchSize
+	}
+	s := &WebhookSink{
+		cfg:  cfg,
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.loop()
+	return s, nil
+}
+
+// Send buffers the event and, once a batch is full, wakes the background
+// flush. It fails only when the backlog is full and the endpoint is still
+// rejecting batches.
+func (s *WebhookSink) Send(ctx context.Context, topic string, value any) error {
+	s.mu.Lock()
+	if len(s.pending)+s.inflight >= s.cfg.MaxPending {
+		err := s.lastErr
+		s.mu.Unlock()
+		return errors.Join(ErrWebhookBacklog, err)
+	}
+	s.pending = append(s.pending, SinkRecord{Topic: topic, Value: value})
+	full := len(s.pending) >= s.cfg.BatchSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default: // a flush is already due
+		}
+	}
+	return nil
+}
+
+// Flush posts everything buffered so far, one batch at a time. Each batch is
+// taken out of the buffer before posting and put back if the post fails.
+func (s *WebhookSink) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	for {
+		s.mu.Lock()
+		n := min(len(s.pending), s.cfg.BatchSize)
+		if n == 0 {
+			s.lastErr = nil
+			s.mu.Unlock()
+			return nil
+		}
+		batch := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		s.inflight = n
+		s.mu.Unlock()
+
+		err := s.post(ctx, batch)
+
+		s.mu.Lock()
+		s.inflight = 0
+		if err != nil {
+			s.pending = append(batch, s.pending...)
+			s.lastErr = err
+		}
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close stops the periodic flush and posts what is left. It is safe to call
+// more than once; later calls return the first call's result.
+func (s *WebhookSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		ctx, cancel := context.WithTimeout(context.Background(), defaultWebhookTimeout)
+		defer cancel()
+		s.closeErr = s.Flush(ctx)
+	})
+	return s.closeErr
+}
+
+func (s *WebhookSink) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), defaultWebhookTimeout)
+		_ = s.Flush(ctx)
+		cancel()
+	}
+}
+
+func (s *WebhookSink) post(ctx context.Context, batch []SinkRecord) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("adapters: webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package adapters_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+func TestJSONLinesSink_WritesOneLinePerEvent(t *testing.T) {
+	var buf bytes.Buffer
+	sink := adapters.NewJSONLinesSink(&buf)
+
+	require.NoError(t, sink.Send(context.Background(), "audit", map[string]int{"n": 1}))
+	require.NoError(t, sink.Send(context.Background(), "usage", map[string]int{"n": 2}))
+
+	sc := bufio.NewScanner(&buf)
+	var lines []string
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"topic":"audit","value":{"n":1}}`, lines[0])
+	assert.JSONEq(t, `{"topic":"usage","value":{"n":2}}`, lines[1])
+}
+
+func TestFileSink_AppendsAndCloses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := adapters.NewFileSink(path)
+	require.NoError(t, err)
+	require.NoError(t, sink.Send(context.Background(), "audit", "e1"))
+	require.NoError(t, sink.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"topic":"audit","value":"e1"}`, string(bytes.TrimSpace(data)))
+}
+
+func TestMemorySink_KeepsMostRecent(t *testing.T) {
+	sink := adapters.NewMemorySink(2)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, sink.Send(context.Background(), "t", i))
+	}
+	assert.Equal(t, []adapters.SinkRecord{{Topic: "t", Value: 2}, {Topic: "t", Value: 3}}, sink.Records())
+
+	sink.Reset()
+	assert.Empty(t, sink.Records())
+}
+
+func TestWebhookSink_PostsBatches(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]adapters.SinkRecord
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var batch []adapters.SinkRecord
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	sink, err := adapters.NewWebhookSink(adapters.WebhookConfig{
+		URL:           srv.URL,
+		Headers:       map[string]string{"Authorization": "Bearer secret"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sink.Send(context.Background(), "t", i))
+	}
+	require.NoError(t, sink.Close()) // flushes the partial batch
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], 2)
+	assert.Len(t, batches[1], 1)
+}
+
+func TestWebhookSink_KeepsBatchOnFailure(t *testing.T) {
+	var fail = true
+	var mu sync.Mutex
+	var received int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []adapters.SinkRecord
+		_ = json.NewDecoder(r.Body).Decode(&batch)
+		received += len(batch)
+	}))
+	defer srv.Close()
+
+	sink, err := adapters.NewWebhookSink(adapters.WebhookConfig{URL: srv.URL, BatchSize: 1, MaxPending: 2, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Send(context.Background(), "t", 1))
+	require.NoError(t, sink.Send(context.Background(), "t", 2))
+	assert.ErrorIs(t, sink.Send(context.Background(), "t", 3), adapters.ErrWebhookBacklog)
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	require.NoError(t, sink.Close())
+	assert.Equal(t, 2, received)
+}
+
+func TestWebhookSink_SlowEndpointDoesNotBlockSend(t *testing.T) {
+	entered, release := make(chan struct{}, 8), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+
+	sink, err := adapters.NewWebhookSink(adapters.WebhookConfig{URL: srv.URL, BatchSize: 1, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Send(context.Background(), "t", 1))
+	select {
+	case <-entered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("full batch was not posted")
+	}
+
+	start := time.Now()
+	require.NoError(t, sink.Send(context.Background(), "t", 2))
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "Send waited for the slow post")
+
+	close(release)
+	require.NoError(t, sink.Close())
+	assert.NotPanics(t, func() { _ = sink.Close() })
+}
+
+type failingSink struct{ panics bool }
+
+func (s failingSink) Send(ctx context.Context, topic string, value any) error {
+	if s.panics {
+		panic("boom")
+	}
+	return errors.New("sink down")
+}
+
+func (s failingSink) Close() error { return nil }
+
+func event(eventType string) sdkmodels.EventJson {
+	return sdkmodels.EventJson{EventType: eventType}
+}
+
+func TestFanOut_RoutesByEventType(t *testing.T) {
+	authz := adapters.NewMemorySink(10)
+	all := adapters.NewMemorySink(10)
+	fan, err := adapters.NewFanOut(
+		adapters.FanOutRoute{Pattern: "authz.*", Sinks: []interfaces.Producer{authz}},
+		adapters.FanOutRoute{Pattern: "*", Sinks: []interfaces.Producer{all, authz}},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, fan.Send(context.Background(), "t", event("authz.denied")))
+	require.NoError(t, fan.Send(context.Background(), "t", event("login.success")))
+
+	assert.Len(t, authz.Records(), 2, "authz sink listed twice must receive each event once")
+	assert.Len(t, all.Records(), 2)
+}
+
+func TestFanOut_IsolatesFailingSinks(t *testing.T) {
+	healthy := adapters.NewMemorySink(10)
+	var reported []error
+	fan, err := adapters.NewFanOut(adapters.FanOutRoute{
+		Pattern: "*",
+		Sinks:   []interfaces.Producer{&failingSink{}, &failingSink{panics: true}, healthy},
+	})
+	require.NoError(t, err)
+	fan.OnError = func(ctx context.Context, sink interfaces.Producer, err error) { reported = append(reported, err) }
+
+	require.NoError(t, fan.Send(context.Background(), "t", event("audit.log")))
+	assert.Len(t, healthy.Records(), 1)
+	assert.Len(t, reported, 2)
+}
+
+// valueSink is a sink whose dynamic type is not comparable.
+type valueSink struct {
+	tags []string
+	mem  *adapters.MemorySink
+}
+
+func (s valueSink) Send(ctx context.Context, topic string, value any) error {
+	return s.mem.Send(ctx, topic, value)
+}
+
+func (s valueSink) Close() error { return nil }
+
+func TestFanOut_NonComparableSink(t *testing.T) {
+	mem := adapters.NewMemorySink(10)
+	healthy := adapters.NewMemorySink(10)
+	sink := valueSink{tags: []string{"siem"}, mem: mem}
+	fan, err := adapters.NewFanOut(
+		adapters.FanOutRoute{Pattern: "audit.*", Sinks: []interfaces.Producer{sink, healthy}},
+		adapters.FanOutRoute{Pattern: "*", Sinks: []interfaces.Producer{healthy}},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, fan.Send(context.Background(), "t", event("audit.log")))
+	assert.Len(t, mem.Records(), 1)
+	assert.Len(t, healthy.Records(), 1, "pointer sinks are still deduplicated")
+	assert.NoError(t, fan.Close())
+}
+
+func TestFanOut_FailsWhenAllSinksFail(t *testing.T) {
+	fan, err := adapters.NewFanOut(adapters.FanOutRoute{Pattern: "*", Sinks: []interfaces.Producer{&failingSink{}}})
+	require.NoError(t, err)
+	assert.Error(t, fan.Send(context.Background(), "t", event("audit.log")))
+}
+
+func TestFanOut_RejectsBadPattern(t *testing.T) {
+	_, err := adapters.NewFanOut(adapters.FanOutRoute{Pattern: "[", Sinks: nil})
+	assert.Error(t, err)
+}
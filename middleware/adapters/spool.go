@@ -0,0 +1,592 @@
+package adapters
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// Spool defaults. The cap is what a node can reasonably give up for a
+// multi-minute broker outage; tune it with SpoolConfig.
+const (
+	defaultSpoolMaxBytes       = 256 << 20
+	defaultSpoolSegmentBytes   = 16 << 20
+	defaultSpoolReplayInterval = time.Second
+
+	spoolSegmentExt   = ".seg"
+	spoolCursorFile   = "cursor"
+	spoolHeaderLength = 8 // uint32 body length + uint32 CRC-32 (IEEE)
+)
+
+// ErrSpoolFull is returned when an event can neither be sent nor spooled
+// because the spool has reached SpoolConfig.MaxBytes.
+var ErrSpoolFull = errors.New("adapters: spool is full")
+
+// ErrSpoolClosed is returned by Send after Close.
+var ErrSpoolClosed = errors.New("adapters: spooling producer is closed")
+
+// SpoolConfig configures a SpoolingProducer. Dir is required; other zero values
+// fall back to the defaults.
+type SpoolConfig struct {
+	Dir            string        // directory holding segment files; created if missing
+	MaxBytes       int64         // cap on the spool's disk usage
+	SegmentBytes   int64         // size at which a new segment file is started
+	ReplayInterval time.Duration // wait between replay attempts while the producer is failing
+	SendTimeout    time.Duration // bound on each replayed send
+
+	// OnError, if set, receives each spooled event that replay skips because
+	// it cannot be decoded.
+	OnError func(ctx context.Context, err error)
+}
+
+func (c SpoolConfig) withDefaults() SpoolConfig {
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = defaultSpoolMaxBytes
+	}
+	if c.SegmentBytes <= 0 {
+		c.SegmentBytes = defaultSpoolSegmentBytes
+	}
+	if c.ReplayInterval <= 0 {
+		c.ReplayInterval = defaultSpoolReplayInterval
+	}
+	if c.SendTimeout <= 0 {
+		c.SendTimeout = defaultSendTimeout
+	}
+	return c
+}
+
+// spoolRecord is the on-disk form of one event.
+type spoolRecord struct {
+	Topic   string            `json:"topic"`
+	Key     string            `json:"key,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Event   bool              `json:"event,omitempty"` // Value is an sdkmodels.EventJson
+	Value   json.RawMessage   `json:"value"`
+}
+
+func newSpoolRecord(topic string, msg interfaces.Message) (spoolRecord, error) {
+	raw, err := json.Marshal(msg.Value)
+	if err != nil {
+		return spoolRecord{}, fmt.Errorf("adapters: encode spooled event: %w", err)
+	}
+	_, isEvent := msg.Value.(sdkmodels.EventJson)
+	return spoolRecord{Topic: topic, Key: msg.Key, Headers: msg.Headers, Event: isEvent, Value: raw}, nil
+}
+
+// value rebuilds what is handed to the wrapped producer on replay: an
+// sdkmodels.EventJson when one was spooled, otherwise the raw JSON.
+func (r spoolRecord) value() (any, error) {
+	if !r.Event {
+		return r.Value, nil
+	}
+	// Decode through a method-less alias: the generated UnmarshalJSON rejects
+	// events that predate required fields (e.g. an empty md5_hash).
+	type plain sdkmodels.EventJson
+	var ev plain
+	if err := json.Unmarshal(r.Value, &ev); err != nil {
+		return nil, err
+	}
+	return sdkmodels.EventJson(ev), nil
+}
+
+type spoolSegment struct {
+	id   uint64
+	size int64
+}
+
+// SpoolingProducer wraps any interfaces.Producer with a local write-ahead log.
+// While the wrapped producer is healthy, Send passes straight through. When it
+// fails, the event is appended to a segmented, fsync'd file under Dir and Send
+// reports success; once anything is spooled, later events are appended too so
+// that replay preserves their order. A background loop replays the spool
+// through the wrapped producer as soon as it recovers.
+//
+// Delivery is at-least-once: an event sent just before a crash may be replayed
+// again after restart.
+type SpoolingProducer struct {
+	inner interfaces.Producer
+	cfg   SpoolConfig
+
+	// sendMu makes SendMessage's depth check and direct send atomic, so an
+	// event cannot overtake one that is being spooled.
+	sendMu sync.Mutex
+
+	mu         sync.Mutex
+	segments   []spoolSegment // oldest first; the last one is being appended to
+	tail       *os.File
+	headOffset int64 // replay position within segments[0]
+	depth      int
+	size       int64
+	closed     bool
+
+	head   *os.File // reader for segments[0]; owned by the replay loop
+	headID uint64
+
+	skipped atomic.Uint64
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+var _ interfaces.MessageProducer = (*SpoolingProducer)(nil)
+
+// NewSpoolingProducer opens (or creates) the spool under cfg.Dir, recovers any
+// events left by a previous process and starts replaying them through inner.
+func NewSpoolingProducer(inner interfaces.Producer, cfg SpoolConfig) (*SpoolingProducer, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("adapters: spool dir is required")
+	}
+	cfg = cfg.withDefaults()
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("adapters: create spool dir: %w", err)
+	}
+
+	s := &SpoolingProducer{
+		inner: inner,
+		cfg:   cfg,
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	go s.replay()
+	return s, nil
+}
+
+// Send delivers value through the wrapped producer, or spools it if that fails
+// or if earlier events are still waiting to be replayed. It returns an error
+// only when the event could not be made durable either way. Sends are
+// serialized so that events keep the order in which Send was called.
+func (s *SpoolingProducer) Send(ctx context.Context, topic string, value any) error {
+	return s.SendMessage(ctx, topic, interfaces.Message{Value: value})
+}
+
+// SendMessage is Send for a message with a key and headers; both are spooled
+// with the event and replayed with it.
+func (s *SpoolingProducer) SendMessage(ctx context.Context, topic string, msg interfaces.Message) error {
+	// Held until the event is sent or spooled: depth can only grow through
+	// here, so if it is zero now nothing is spooled ahead of this event.
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSpoolClosed
+	}
+	spooling := s.depth > 0
+	s.mu.Unlock()
+
+	var sendErr error
+	if !spooling {
+		if sendErr = sendMessage(ctx, s.inner, topic, msg); sendErr == nil {
+			return nil
+		}
+	}
+
+	rec, err := newSpoolRecord(topic, msg)
+	if err != nil {
+		return errors.Join(sendErr, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.Join(sendErr, ErrSpoolClosed)
+	}
+	if err := s.appendLocked(rec); err != nil {
+		return errors.Join(sendErr, err)
+	}
+	return nil
+}
+
+// Depth returns the number of events waiting in the spool.
+func (s *SpoolingProducer) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.depth
+}
+
+// SizeBytes returns the spool's current disk usage.
+func (s *SpoolingProducer) SizeBytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Skipped returns the number of spooled events replay has skipped because
+// they could not be decoded.
+func (s *SpoolingProducer) Skipped() uint64 {
+	return s.skipped.Load()
+}
+
+// dropsKeys reports whether the wrapped producer ignores message keys.
+func (s *SpoolingProducer) dropsKeys() bool {
+	kd, ok := s.inner.(keyDropper)
+	return ok && kd.dropsKeys()
+}
+
+// Close stops replaying, closes the spool files and then the wrapped producer.
+// Events still spooled stay on disk and are replayed by the next
+// SpoolingProducer opened on the same Dir.
+func (s *SpoolingProducer) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	err := s.tail.Close()
+	s.mu.Unlock()
+	if s.head != nil {
+		_ = s.head.Close()
+	}
+	return errors.Join(err, s.inner.Close())
+}
+
+// ---------- replay ----------
+
+func (s *SpoolingProducer) replay() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if s.Depth() == 0 {
+			select {
+			case <-s.wake:
+				continue
+			case <-s.stop:
+				return
+			}
+		}
+		if err := s.replayOne(); err != nil {
+			select {
+			case <-time.After(s.cfg.ReplayInterval):
+			case <-s.stop:
+				return
+			}
+		}
+	}
+}
+
+// replayOne sends the oldest spooled event and, on success, advances past it.
+func (s *SpoolingProducer) replayOne() error {
+	s.mu.Lock()
+	seg, off := s.segments[0], s.headOffset
+	s.mu.Unlock()
+
+	if err := s.openHead(seg.id); err != nil {
+		return err
+	}
+	rec, n, err := readSpoolRecord(s.head, off, s.cfg.MaxBytes)
+	if err != nil {
+		return err
+	}
+
+	// An undecodable record can never be delivered; skip it rather than
+	// blocking the rest of the spool behind it.
+	if value, decErr := rec.value(); decErr == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.SendTimeout)
+		err = sendMessage(ctx, s.inner, rec.Topic, interfaces.Message{Key: rec.Key, Headers: rec.Headers, Value: value})
+		cancel()
+		if err != nil {
+			return err
+		}
+	} else {
+		s.skipped.Add(1)
+		if s.cfg.OnError != nil {
+			s.cfg.OnError(context.Background(), fmt.Errorf("adapters: skipped undecodable spooled event for topic %q: %w", rec.Topic, decErr))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headOffset += n
+	s.depth--
+	if s.headOffset >= s.segments[0].size {
+		if err := s.retireHeadLocked(); err != nil {
+			return err
+		}
+	}
+	return s.writeCursorLocked()
+}
+
+func (s *SpoolingProducer) openHead(id uint64) error {
+	if s.head != nil && s.headID == id {
+		return nil
+	}
+	if s.head != nil {
+		_ = s.head.Close()
+	}
+	f, err := os.Open(s.segmentPath(id))
+	if err != nil {
+		s.head = nil
+		return err
+	}
+	s.head, s.headID = f, id
+	return nil
+}
+
+// retireHeadLocked deletes the fully replayed head segment. If it is also the
+// segment being appended to, a fresh one replaces it.
+func (s *SpoolingProducer) retireHeadLocked() error {
+	old := s.segments[0]
+	if len(s.segments) == 1 {
+		if err := s.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	if s.head != nil && s.headID == old.id {
+		_ = s.head.Close()
+		s.head = nil
+	}
+	s.segments = s.segments[1:]
+	s.headOffset = 0
+	s.size -= old.size
+	return os.Remove(s.segmentPath(old.id))
+}
+
+// ---------- append ----------
+
+func (s *SpoolingProducer) appendLocked(rec spoolRecord) error {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, spoolHeaderLength+len(body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(body))
+	copy(buf[spoolHeaderLength:], body)
+
+	if s.size+int64(len(buf)) > s.cfg.MaxBytes {
+		return ErrSpoolFull
+	}
+	last := &s.segments[len(s.segments)-1]
+	if last.size > 0 && last.size+int64(len(buf)) > s.cfg.SegmentBytes {
+		if err := s.rotateLocked(); err != nil {
+			return err
+		}
+		last = &s.segments[len(s.segments)-1]
+	}
+
+	if _, err := s.tail.Write(buf); err != nil {
+		return fmt.Errorf("adapters: write spool: %w", err)
+	}
+	if err := s.tail.Sync(); err != nil {
+		return fmt.Errorf("adapters: sync spool: %w", err)
+	}
+	last.size += int64(len(buf))
+	s.size += int64(len(buf))
+	s.depth++
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// rotateLocked starts a new segment for appends.
+func (s *SpoolingProducer) rotateLocked() error {
+	id := s.segments[len(s.segments)-1].id + 1
+	f, err := s.createSegment(id)
+	if err != nil {
+		return err
+	}
+	_ = s.tail.Close()
+	s.tail = f
+	s.segments = append(s.segments, spoolSegment{id: id})
+	return nil
+}
+
+func (s *SpoolingProducer) createSegment(id uint64) (*os.File, error) {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("adapters: create spool segment: %w", err)
+	}
+	if err := syncDir(s.cfg.Dir); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// ---------- recovery ----------
+
+// load rebuilds the in-memory state from Dir: it drops segments already
+// replayed according to the cursor, counts the remaining records and truncates
+// any torn write left by a crash.
+func (s *SpoolingProducer) load() error {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("adapters: read spool dir: %w", err)
+	}
+	var ids []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), spoolSegmentExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if id, err := strconv.ParseUint(name, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	curID, curOff := s.readCursor()
+	for _, id := range ids {
+		if id < curID {
+			_ = os.Remove(s.segmentPath(id))
+			continue
+		}
+		start := int64(0)
+		if id == curID {
+			start = curOff
+		}
+		size, count, err := s.scanSegment(id, start)
+		if err != nil {
+			return err
+		}
+		if len(s.segments) == 0 {
+			s.headOffset = start
+		}
+		s.segments = append(s.segments, spoolSegment{id: id, size: size})
+		s.depth += count
+		s.size += size
+	}
+
+	if len(s.segments) == 0 {
+		s.segments = []spoolSegment{{id: curID + 1}}
+		s.headOffset = 0
+	}
+	last := s.segments[len(s.segments)-1]
+	s.tail, err = s.createSegment(last.id)
+	return err
+}
+
+// scanSegment counts the intact records from start and truncates the file at
+// the first torn or corrupt one.
+func (s *SpoolingProducer) scanSegment(id uint64, start int64) (int64, int, error) {
+	path := s.segmentPath(id)
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("adapters: open spool segment: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	off, count := int64(0), 0
+	for {
+		_, n, err := readSpoolRecord(f, off, s.cfg.MaxBytes)
+		if err != nil {
+			break
+		}
+		if off >= start {
+			count++
+		}
+		off += n
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	if info.Size() != off {
+		if err := os.Truncate(path, off); err != nil {
+			return 0, 0, fmt.Errorf("adapters: truncate torn spool segment: %w", err)
+		}
+	}
+	return off, count, nil
+}
+
+func (s *SpoolingProducer) readCursor() (uint64, int64) {
+	b, err := os.ReadFile(filepath.Join(s.cfg.Dir, spoolCursorFile))
+	if err != nil {
+		return 0, 0
+	}
+	var id uint64
+	var off int64
+	if _, err := fmt.Sscanf(string(b), "%d %d", &id, &off); err != nil {
+		return 0, 0
+	}
+	return id, off
+}
+
+// writeCursorLocked records the replay position, atomically via rename. It is
+// not fsync'd: losing it only means replaying a few events twice.
+func (s *SpoolingProducer) writeCursorLocked() error {
+	path := filepath.Join(s.cfg.Dir, spoolCursorFile)
+	tmp := path + ".tmp"
+	data := fmt.Sprintf("%d %d", s.segments[0].id, s.headOffset)
+	if err := os.WriteFile(tmp, []byte(data), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *SpoolingProducer) segmentPath(id uint64) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("%020d%s", id, spoolSegmentExt))
+}
+
+// readSpoolRecord reads the record at off, returning it and its on-disk length.
+// A length prefix above maxLen is treated as corruption.
+func readSpoolRecord(f *os.File, off, maxLen int64) (spoolRecord, int64, error) {
+	var hdr [spoolHeaderLength]byte
+	if _, err := f.ReadAt(hdr[:], off); err != nil {
+		return spoolRecord{}, 0, err
+	}
+	length := binary.BigEndian.Uint32(hdr[0:4])
+	sum := binary.BigEndian.Uint32(hdr[4:8])
+	if int64(length) > maxLen {
+		return spoolRecord{}, 0, errors.New("adapters: spool record length out of range")
+	}
+
+	body := make([]byte, length)
+	if _, err := f.ReadAt(body, off+spoolHeaderLength); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return spoolRecord{}, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != sum {
+		return spoolRecord{}, 0, errors.New("adapters: spool record checksum mismatch")
+	}
+	var rec spoolRecord
+	if err := json.Unmarshal(body, &rec); err != nil {
+		return spoolRecord{}, 0, err
+	}
+	return rec, spoolHeaderLength + int64(length), nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
@@ -0,0 +1,243 @@
+package adapters_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+)
+
+// switchProducer fails every Send while down is set.
+type switchProducer struct {
+	mu   sync.Mutex
+	down bool
+	sent []sentMessage
+}
+
+func (p *switchProducer) setDown(down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = down
+}
+
+func (p *switchProducer) Send(ctx context.Context, topic string, value any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		return errors.New("broker unavailable")
+	}
+	p.sent = append(p.sent, sentMessage{topic: topic, value: value})
+	return nil
+}
+
+func (p *switchProducer) Close() error { return nil }
+
+func (p *switchProducer) messages() []sentMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]sentMessage(nil), p.sent...)
+}
+
+func spoolConfig(t *testing.T) adapters.SpoolConfig {
+	return adapters.SpoolConfig{
+		Dir:            t.TempDir(),
+		SegmentBytes:   512, // force several segments
+		ReplayInterval: 5 * time.Millisecond,
+	}
+}
+
+func TestSpoolingProducer_PassesThroughWhenHealthy(t *testing.T) {
+	inner := &switchProducer{}
+	sp, err := adapters.NewSpoolingProducer(inner, spoolConfig(t))
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	require.NoError(t, sp.Send(context.Background(), "audit", "e1"))
+	assert.Equal(t, []sentMessage{{topic: "audit", value: "e1"}}, inner.messages())
+	assert.Zero(t, sp.Depth())
+}
+
+func TestSpoolingProducer_SpoolsAndReplaysInOrder(t *testing.T) {
+	inner := &switchProducer{down: true}
+	sp, err := adapters.NewSpoolingProducer(inner, spoolConfig(t))
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, sp.Send(context.Background(), "usage", i))
+	}
+	assert.Equal(t, 20, sp.Depth())
+	assert.Positive(t, sp.SizeBytes())
+
+	inner.setDown(false)
+	require.Eventually(t, func() bool { return sp.Depth() == 0 }, 2*time.Second, 5*time.Millisecond)
+
+	msgs := inner.messages()
+	require.Len(t, msgs, 20)
+	for i, m := range msgs {
+		assert.Equal(t, "usage", m.topic)
+		assert.JSONEq(t, string(mustJSON(t, i)), string(m.value.(json.RawMessage)))
+	}
+	assert.Zero(t, sp.SizeBytes())
+}
+
+func TestSpoolingProducer_ReplaysEventJson(t *testing.T) {
+	inner := &switchProducer{down: true}
+	sp, err := adapters.NewSpoolingProducer(inner, spoolConfig(t))
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	event := sdkmodels.EventJson{Id: uuid.New(), EventType: "audit.log", Timestamp: time.Now().UTC()}
+	require.NoError(t, sp.Send(context.Background(), "audit", event))
+
+	inner.setDown(false)
+	require.Eventually(t, func() bool { return len(inner.messages()) == 1 }, 2*time.Second, 5*time.Millisecond)
+	got, ok := inner.messages()[0].value.(sdkmodels.EventJson)
+	require.True(t, ok, "replayed value should be sdkmodels.EventJson")
+	assert.Equal(t, event.Id, got.Id)
+	assert.Equal(t, "audit.log", got.EventType)
+}
+
+func TestSpoolingProducer_SurvivesRestart(t *testing.T) {
+	cfg := spoolConfig(t)
+	inner := &switchProducer{down: true}
+	sp, err := adapters.NewSpoolingProducer(inner, cfg)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, sp.Send(context.Background(), "usage", i))
+	}
+	require.NoError(t, sp.Close())
+
+	recovered := &switchProducer{}
+	sp2, err := adapters.NewSpoolingProducer(recovered, cfg)
+	require.NoError(t, err)
+	defer func() { _ = sp2.Close() }()
+
+	require.Eventually(t, func() bool { return sp2.Depth() == 0 }, 2*time.Second, 5*time.Millisecond)
+	assert.Len(t, recovered.messages(), 5)
+}
+
+func TestSpoolingProducer_CapsDiskUsage(t *testing.T) {
+	cfg := spoolConfig(t)
+	cfg.MaxBytes = 200
+	sp, err := adapters.NewSpoolingProducer(&switchProducer{down: true}, cfg)
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	var full error
+	for i := 0; i < 50 && full == nil; i++ {
+		full = sp.Send(context.Background(), "usage", i)
+	}
+	assert.ErrorIs(t, full, adapters.ErrSpoolFull)
+	assert.LessOrEqual(t, sp.SizeBytes(), int64(200))
+}
+
+func TestSpoolingProducer_ReportsUndecodableRecords(t *testing.T) {
+	cfg := spoolConfig(t)
+	writeSegment(t, cfg.Dir, 1,
+		`{"topic":"audit","event":true,"value":"not an event"}`,
+		`{"topic":"usage","value":7}`,
+	)
+	var mu sync.Mutex
+	var reported []error
+	cfg.OnError = func(_ context.Context, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}
+
+	inner := &switchProducer{}
+	sp, err := adapters.NewSpoolingProducer(inner, cfg)
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	require.Eventually(t, func() bool { return sp.Depth() == 0 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, uint64(1), sp.Skipped())
+	mu.Lock()
+	require.Len(t, reported, 1)
+	assert.ErrorContains(t, reported[0], `topic "audit"`)
+	mu.Unlock()
+	require.Len(t, inner.messages(), 1, "the next record is still replayed")
+	assert.Equal(t, "usage", inner.messages()[0].topic)
+}
+
+// stallingProducer blocks its first Send until release is closed and then
+// fails it; later sends succeed.
+type stallingProducer struct {
+	switchProducer
+	calls   atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p *stallingProducer) Send(ctx context.Context, topic string, value any) error {
+	if p.calls.Add(1) == 1 {
+		close(p.entered)
+		<-p.release
+		return errors.New("broker unavailable")
+	}
+	return p.switchProducer.Send(ctx, topic, value)
+}
+
+func TestSpoolingProducer_NoOvertakingWhileSpooling(t *testing.T) {
+	inner := &stallingProducer{entered: make(chan struct{}), release: make(chan struct{})}
+	sp, err := adapters.NewSpoolingProducer(inner, spoolConfig(t))
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, sp.Send(context.Background(), "usage", 1))
+	}()
+	<-inner.entered
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, sp.Send(context.Background(), "usage", 2))
+	}()
+	time.Sleep(20 * time.Millisecond) // let the second Send reach the producer if it can
+	close(inner.release)
+	wg.Wait()
+
+	require.Eventually(t, func() bool { return len(inner.messages()) == 2 }, 2*time.Second, 5*time.Millisecond)
+	for i, m := range inner.messages() {
+		// Replayed events arrive as raw JSON, direct sends as the value.
+		assert.JSONEq(t, string(mustJSON(t, i+1)), string(mustJSON(t, m.value)), "event %d", i)
+	}
+}
+
+// writeSegment writes records, framed as the spool stores them, to segment id.
+func writeSegment(t *testing.T, dir string, id int, records ...string) {
+	t.Helper()
+	var buf []byte
+	for _, r := range records {
+		hdr := make([]byte, 8)
+		binary.BigEndian.PutUint32(hdr[0:4], uint32(len(r)))
+		binary.BigEndian.PutUint32(hdr[4:8], crc32.ChecksumIEEE([]byte(r)))
+		buf = append(append(buf, hdr...), r...)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%020d.seg", id)), buf, 0o600))
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
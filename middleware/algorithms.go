@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// JWS algorithms AuthenticationMiddleware can verify. Symmetric (HS*) and
+// unsigned ("none") tokens are never accepted.
+const (
+	AlgRS256 = "RS256"
+	AlgRS384 = "RS384"
+	AlgRS512 = "RS512"
+	AlgES256 = "ES256"
+	AlgES384 = "ES384"
+	AlgEdDSA = "EdDSA"
+)
+
+// supportedAlgorithms is also the default allowlist.
+var supportedAlgorithms = []string{AlgRS256, AlgRS384, AlgRS512, AlgES256, AlgES384, AlgEdDSA}
+
+// WithAlgorithms restricts the accepted token `alg` values to algs (default:
+// every supported algorithm). AuthenticationMiddleware fails if algs names an
+// unsupported algorithm; "none" and HS* are never supported.
+func WithAlgorithms(algs ...string) AuthOption {
+	return func(a *authConfig) { a.algorithms = algs }
+}
+
+// validateAlgorithms checks an allowlist from WithAlgorithms.
+func validateAlgorithms(algs []string) error {
+	if len(algs) == 0 {
+		return errors.New("algorithm allowlist is empty")
+	}
+	for _, alg := range algs {
+		if strings.EqualFold(alg, "none") || strings.HasPrefix(strings.ToUpper(alg), "HS") {
+			return fmt.Errorf("algorithm %q is never accepted", alg)
+		}
+		if !slices.Contains(supportedAlgorithms, alg) {
+			return fmt.Errorf("unsupported algorithm %q", alg)
+		}
+	}
+	return nil
+}
+
+// keyMatchesAlg reports whether key can verify alg: RS* needs an RSA key,
+// ES256/ES384 an ECDSA key on P-256/P-384, EdDSA an Ed25519 key.
+// errKeyAlgMismatch marks a key that exists but may not verify the token's alg.
+var errKeyAlgMismatch = errors.New("key/alg mismatch")
+
+func keyMatchesAlg(key crypto.PublicKey, alg string) error {
+	ok := false
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		ok = alg == AlgRS256 || alg == AlgRS384 || alg == AlgRS512
+	case *ecdsa.PublicKey:
+		ok = (alg == AlgES256 && k.Curve == elliptic.P256()) ||
+			(alg == AlgES384 && k.Curve == elliptic.P384())
+	case ed25519.PublicKey:
+		ok = alg == AlgEdDSA
+	}
+	if !ok {
+		return fmt.Errorf("%w: key type %T cannot verify %s", errKeyAlgMismatch, key, alg)
+	}
+	return nil
+}
+
+// ParsePublicKey parses a PEM-encoded RSA (PKIX or PKCS1), ECDSA P-256/P-384
+// or Ed25519 public key.
+func ParsePublicKey(pemKey string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block containing public key")
+	}
+	return parsePublicKeyBlock(block)
+}
+
+func parsePublicKeyBlock(block *pem.Block) (crypto.PublicKey, error) {
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		rsaPub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return rsaPub, nil
+	}
+	switch k := pub.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return k, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() && k.Curve != elliptic.P384() {
+			return nil, fmt.Errorf("unsupported EC curve %s", k.Curve.Params().Name)
+		}
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", pub)
+}
+
+// jwkToPublicKey converts an RSA, EC (P-256/P-384) or OKP (Ed25519) JWK.
+func jwkToPublicKey(k jwk) (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		return jwkToRSAPublicKey(k)
+	case "EC":
+		return jwkToECPublicKey(k)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key length")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func jwkToECPublicKey(k jwk) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("decode x: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("decode y: %w", err)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x) != size || len(y) != size {
+		return nil, errors.New("invalid EC coordinate length")
+	}
+	// Uncompressed SEC 1 point; parsing rejects points not on the curve.
+	point := append(append([]byte{4}, x...), y...)
+	return ecdsa.ParseUncompressedPublicKey(curve, point)
+}
@@ -7,17 +7,12 @@ import (
 
 	"net/http"
 	"strings"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 
-	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
-	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/utils"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
 	ctx "github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/claims"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
-	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
 )
 
 // -------- helpers --------
@@ -47,6 +42,7 @@ func (w *responseWriter) Write(b []byte) (int, error) {
 // AuditMiddleware emits audit logs to Kafka.
 // It reads/restores the body ONLY for JSON requests on mutating methods.
 func AuditMiddleware(cfg interfaces.Config, logger interfaces.Logger, producer *adapters.ProducerAdapter, topic string) echo.MiddlewareFunc {
+	events := NewEventBuilder(cfg)
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			req := c.Request()
@@ -105,12 +101,6 @@ func AuditMiddleware(cfg interfaces.Config, logger interfaces.Logger, producer *
 				return echo.ErrUnauthorized
 			}
 
-			// Parse (or generate) request ID set byt RequestID middleware
-			requestID := requestctx.GetOrNewRequestUUID(c.Request().Context())
-
-			// Parse (or generate) session ID set byt RequestID middleware
-			sessionID := requestctx.GetOrNewSessionUUID(c.Request().Context())
-
 			tenantID, err := claims.GetTenantId()
 			if err != nil {
 				logger.Error(c.Request().Context(), "Invalid tenant_id from userContext: %s", claims.Rsc)
@@ -123,31 +113,22 @@ func AuditMiddleware(cfg interfaces.Config, logger interfaces.Logger, producer *
 				message = &val
 			}
 
-			event := sdkmodels.EventJson{
-				Id:          uuid.New(),
-				RequestId:   requestID,
-				SessionId:   sessionID,
-				TenantId:    tenantID,
-				EventType:   "audit.log",
-				EventSource: utils.CreateServicePrincipleID(cfg),
-				Timestamp:   time.Now().UTC(),
-				Message:     message,
-				Payload: &map[string]any{
-					"jti":              claims.Jti,
-					"http_method":      req.Method,
-					"resource":         deriveResource(c.Path()),
-					"endpoint":         c.Path(),
-					"full_url":         req.URL.String(),
-					"source_ip":        req.RemoteAddr,
-					"user_agent":       req.UserAgent(),
-					"payload":          payload,
-					"subject":          claims.Sub,
-					"status_code":      statusCode,
-					"response_payload": responsePayload,
-				},
-			}
-
-			sendEventAsync(reqCtx, producer, logger, topic, event, "audit.log")
+			event := events.Build(reqCtx, EventTypeAuditLog, AuditPayload{
+				SchemaVersion:   PayloadSchemaVersion,
+				Jti:             claims.Jti,
+				HTTPMethod:      req.Method,
+				Resource:        deriveResource(c.Path()),
+				Endpoint:        c.Path(),
+				FullURL:         req.URL.String(),
+				SourceIP:        req.RemoteAddr,
+				UserAgent:       req.UserAgent(),
+				Payload:         payload,
+				Subject:         claims.Sub,
+				StatusCode:      statusCode,
+				ResponsePayload: responsePayload,
+			}, WithEventTenant(tenantID), WithEventMessage(message))
+
+			sendEventAsync(reqCtx, producer, logger, topic, event, EventTypeAuditLog)
 
 			return callErr
 		}
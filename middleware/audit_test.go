@@ -80,24 +80,19 @@ func TestAuditMiddleware_BasicFlow(t *testing.T) {
 	// Extract payload and verify audit-specific fields
 	assert.NotNil(t, eventJson.Payload, "Payload should not be nil")
 
-	payloadPtr, ok := eventJson.Payload.(*map[string]any)
-	assert.True(t, ok, "Payload should be a map[string]any")
-	assert.NotNil(t, payloadPtr)
-
-	payloadMap := *payloadPtr
-	assert.Equal(t, "POST", payloadMap["http_method"])
-	assert.Equal(t, "api", payloadMap["resource"])
-	assert.Equal(t, "/api/audit/v1/", payloadMap["endpoint"])
-	assert.Equal(t, "/api/audit/v1/", payloadMap["full_url"])
-	assert.Equal(t, "user@email.com", payloadMap["subject"])
+	auditPayload, ok := eventJson.Payload.(middleware.AuditPayload)
+	assert.True(t, ok, "Payload should be a middleware.AuditPayload")
+
+	assert.Equal(t, middleware.PayloadSchemaVersion, auditPayload.SchemaVersion)
+	assert.Equal(t, "POST", auditPayload.HTTPMethod)
+	assert.Equal(t, "api", auditPayload.Resource)
+	assert.Equal(t, "/api/audit/v1/", auditPayload.Endpoint)
+	assert.Equal(t, "/api/audit/v1/", auditPayload.FullURL)
+	assert.Equal(t, "user@email.com", auditPayload.Subject)
 	assert.Equal(t, utils.CreateServicePrincipleID(cfg), eventJson.EventSource)
 
-	// Check request body in payload - it's stored as "payload" field with json.RawMessage
-	if payloadInterface, exists := payloadMap["payload"]; exists {
-		payloadRaw, ok := payloadInterface.(json.RawMessage)
-		assert.True(t, ok, "Payload should be json.RawMessage")
-		assert.JSONEq(t, string(bodyBytes), string(payloadRaw))
-	}
+	// Check request body in payload
+	assert.JSONEq(t, string(bodyBytes), string(auditPayload.Payload))
 }
 
 func TestAuditMiddleware_NonJSON_DoesNotDrainBody(t *testing.T) {
@@ -151,21 +146,10 @@ func TestAuditMiddleware_NonJSON_DoesNotDrainBody(t *testing.T) {
 	assert.Equal(t, bin, seenBody)
 
 	eventJson := mp.Value().(sdkmodels.EventJson)
-	if eventJson.Payload != nil {
-		if payloadPtr, ok := eventJson.Payload.(*map[string]any); ok {
-			payloadMap := *payloadPtr
-			if p, ok := payloadMap["payload"]; ok {
-				if rm, ok := p.(json.RawMessage); ok {
-					// Accept missing or empty payload for non-JSON
-					if len(rm) != 0 {
-						t.Fatalf("payload should be empty for non-JSON requests, got: %q", string(rm))
-					}
-				} else if p != nil {
-					t.Fatalf("payload has unexpected type: %T", p)
-				}
-			}
-		}
-	}
+	auditPayload, ok := eventJson.Payload.(middleware.AuditPayload)
+	assert.True(t, ok, "Payload should be a middleware.AuditPayload")
+	// Accept missing or empty payload for non-JSON
+	assert.Empty(t, auditPayload.Payload, "payload should be empty for non-JSON requests")
 }
 
 func TestAuditMiddleware_SuccessResponse_NoResponseBody(t *testing.T) {
@@ -207,24 +191,21 @@ func TestAuditMiddleware_SuccessResponse_NoResponseBody(t *testing.T) {
 	assert.Equal(t, requestID, eventJson.RequestId)
 	assert.NotNil(t, eventJson.Payload)
 
-	payloadPtr, ok := eventJson.Payload.(*map[string]any)
-	assert.True(t, ok, "Payload should be a map[string]any")
-	payloadMap := *payloadPtr
+	auditPayload, ok := eventJson.Payload.(middleware.AuditPayload)
+	assert.True(t, ok, "Payload should be a middleware.AuditPayload")
 
 	// Verify audit fields
-	assert.Equal(t, "POST", payloadMap["http_method"])
-	assert.Equal(t, "api", payloadMap["resource"])
-	assert.Equal(t, "/api/audit/v1/", payloadMap["endpoint"])
-	assert.Equal(t, "user@email.com", payloadMap["subject"])
-	assert.Equal(t, 201, payloadMap["status_code"])
+	assert.Equal(t, "POST", auditPayload.HTTPMethod)
+	assert.Equal(t, "api", auditPayload.Resource)
+	assert.Equal(t, "/api/audit/v1/", auditPayload.Endpoint)
+	assert.Equal(t, "user@email.com", auditPayload.Subject)
+	assert.Equal(t, 201, auditPayload.StatusCode)
 
 	// Success response should NOT capture response body
-	assert.Nil(t, payloadMap["response_payload"], "Success responses should not capture response body")
+	assert.Nil(t, auditPayload.ResponsePayload, "Success responses should not capture response body")
 
 	// Verify request body was captured
-	if reqPayload, ok := payloadMap["payload"].(json.RawMessage); ok {
-		assert.JSONEq(t, string(bodyBytes), string(reqPayload))
-	}
+	assert.JSONEq(t, string(bodyBytes), string(auditPayload.Payload))
 }
 
 func TestAuditMiddleware_ErrorResponse_CapturesResponseBody(t *testing.T) {
@@ -278,27 +259,22 @@ func TestAuditMiddleware_ErrorResponse_CapturesResponseBody(t *testing.T) {
 	eventJson, ok := mock.Value().(sdkmodels.EventJson)
 	assert.True(t, ok, "Producer value should be an EventJson")
 
-	payloadPtr, ok := eventJson.Payload.(*map[string]any)
-	payloadMap := *payloadPtr
-	assert.True(t, ok, "Payload should be a map[string]any")
+	auditPayload, ok := eventJson.Payload.(middleware.AuditPayload)
+	assert.True(t, ok, "Payload should be a middleware.AuditPayload")
 
-	assert.Equal(t, 400, payloadMap["status_code"])
+	assert.Equal(t, 400, auditPayload.StatusCode)
 
 	// Error response SHOULD capture response body in audit log
-	assert.NotNil(t, payloadMap["response_payload"], "Error responses should capture response body")
-
-	if respPayload, ok := payloadMap["response_payload"].(json.RawMessage); ok {
-		var auditedResponse map[string]string
-		err := json.Unmarshal(respPayload, &auditedResponse)
-		assert.NoError(t, err)
-		assert.Equal(t, "validation failed", auditedResponse["error"])
-		assert.Equal(t, "email", auditedResponse["field"])
-
-		// Verify audit log matches what client received
-		assert.Equal(t, clientResponse, auditedResponse, "Audited response should match client response")
-	} else {
-		t.Fatal("response_payload should be json.RawMessage")
-	}
+	assert.NotNil(t, auditPayload.ResponsePayload, "Error responses should capture response body")
+
+	var auditedResponse map[string]string
+	err = json.Unmarshal(auditPayload.ResponsePayload, &auditedResponse)
+	assert.NoError(t, err)
+	assert.Equal(t, "validation failed", auditedResponse["error"])
+	assert.Equal(t, "email", auditedResponse["field"])
+
+	// Verify audit log matches what client received
+	assert.Equal(t, clientResponse, auditedResponse, "Audited response should match client response")
 }
 
 func TestAuditMiddleware_ServerError_CapturesResponseBody(t *testing.T) {
@@ -333,10 +309,9 @@ func TestAuditMiddleware_ServerError_CapturesResponseBody(t *testing.T) {
 	assert.True(t, mock.WaitForSend(time.Second), "Producer should have been called within 1s")
 
 	eventJson := mock.Value().(sdkmodels.EventJson)
-	payloadPtr, ok := eventJson.Payload.(*map[string]any)
-	assert.True(t, ok, "Payload should be a map[string]any")
-	payloadMap := *payloadPtr
+	auditPayload, ok := eventJson.Payload.(middleware.AuditPayload)
+	assert.True(t, ok, "Payload should be a middleware.AuditPayload")
 
-	assert.Equal(t, 500, payloadMap["status_code"])
-	assert.NotNil(t, payloadMap["response_payload"], "5xx responses should capture response body")
+	assert.Equal(t, 500, auditPayload.StatusCode)
+	assert.NotNil(t, auditPayload.ResponsePayload, "5xx responses should capture response body")
 }
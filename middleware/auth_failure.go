@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+	httpErr "github.com/grasp-labs/ds-go-commonmodels/v3/commonmodels/http_error"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/internal/models"
+)
+
+// AuthFailureReason says why AuthenticationMiddleware rejected a request. It
+// is the `reason` of login.failure events and picks the RFC 6750 error in the
+// WWW-Authenticate challenge.
+type AuthFailureReason string
+
+// Authentication failure reasons. ReasonMissingToken is reported without an
+// error code, the DPoP proof reasons as error="invalid_dpop_proof" (RFC 9449)
+// and all others as error="invalid_token".
+const (
+	ReasonMissingToken        AuthFailureReason = "missing_token"           // no bearer token in the request
+	ReasonMalformedToken      AuthFailureReason = "malformed_token"         // not a parseable JWT
+	ReasonExpired             AuthFailureReason = "token_expired"           // exp has passed
+	ReasonNotYetValid         AuthFailureReason = "token_not_yet_valid"     // nbf or iat in the future
+	ReasonInvalidSignature    AuthFailureReason = "invalid_signature"       // signature does not verify
+	ReasonUnsupportedAlg      AuthFailureReason = "unsupported_alg"         // alg not on the allowlist
+	ReasonUnknownKey          AuthFailureReason = "unknown_kid"             // no key for the token's kid
+	ReasonKeyUnavailable      AuthFailureReason = "key_unavailable"         // key source failed (e.g. JWKS down)
+	ReasonUntrustedIssuer     AuthFailureReason = "untrusted_issuer"        // iss is not a trusted issuer
+	ReasonInvalidAudience     AuthFailureReason = "invalid_audience"        // aud lacks an accepted audience
+	ReasonInvalidClass        AuthFailureReason = "invalid_cls"             // cls not allowed for the issuer
+	ReasonInvalidClaims       AuthFailureReason = "invalid_claims"          // sub, rsc or another claim is invalid
+	ReasonRevoked             AuthFailureReason = "token_revoked"           // revoked (WithRevocationChecker)
+	ReasonRevocationFailed    AuthFailureReason = "revocation_check_failed" // the revocation checker failed
+	ReasonInactiveToken       AuthFailureReason = "token_inactive"          // introspection says not active
+	ReasonIntrospectionFailed AuthFailureReason = "introspection_failed"    // introspection endpoint failed
+	ReasonInvalidDPoPProof    AuthFailureReason = "invalid_dpop_proof"      // DPoP proof missing or invalid
+	ReasonDPoPReplayed        AuthFailureReason = "dpop_proof_replayed"     // DPoP proof jti already used
+	ReasonDPoPKeyMismatch     AuthFailureReason = "dpop_key_mismatch"       // cnf.jkt missing or not the proof key
+)
+
+// description is the client-facing error_description. It never includes token
+// or configuration values.
+func (r AuthFailureReason) description() string {
+	switch r {
+	case ReasonMalformedToken:
+		return "The access token is malformed"
+	case ReasonExpired:
+		return "The access token expired"
+	case ReasonNotYetValid:
+		return "The access token is not yet valid"
+	case ReasonInvalidSignature:
+		return "The access token signature is invalid"
+	case ReasonUnsupportedAlg:
+		return "The access token signing algorithm is not accepted"
+	case ReasonUnknownKey:
+		return "The access token signing key is unknown"
+	case ReasonKeyUnavailable:
+		return "The access token signing key could not be retrieved"
+	case ReasonUntrustedIssuer:
+		return "The access token issuer is not trusted"
+	case ReasonInvalidAudience:
+		return "The access token is not intended for this resource"
+	case ReasonInvalidClass:
+		return "The access token principal class is not accepted"
+	case ReasonInvalidClaims:
+		return "The access token claims are invalid"
+	case ReasonRevoked:
+		return "The access token has been revoked"
+	case ReasonRevocationFailed:
+		return "The access token revocation status could not be checked"
+	case ReasonInactiveToken:
+		return "The access token is not active"
+	case ReasonIntrospectionFailed:
+		return "The access token could not be introspected"
+	case ReasonInvalidDPoPProof:
+		return "The DPoP proof is missing or invalid"
+	case ReasonDPoPReplayed:
+		return "The DPoP proof has already been used"
+	case ReasonDPoPKeyMismatch:
+		return "The access token is not bound to the DPoP proof key"
+	}
+	return ""
+}
+
+// errorCode is the challenge's error parameter: none for a missing token
+// (RFC 6750 §3.1), invalid_dpop_proof for a bad proof (RFC 9449 §7.1),
+// otherwise invalid_token.
+func (r AuthFailureReason) errorCode() string {
+	switch r {
+	case ReasonMissingToken:
+		return ""
+	case ReasonInvalidDPoPProof, ReasonDPoPReplayed:
+		return "invalid_dpop_proof"
+	}
+	return "invalid_token"
+}
+
+// AuthError is an authentication failure with its reason. The errors the
+// middleware hands to echo wrap it, so an HTTPErrorHandler can recover the
+// reason with errors.As.
+type AuthError struct {
+	Reason AuthFailureReason
+	Err    error
+}
+
+func (e *AuthError) Error() string {
+	if e.Err == nil {
+		return string(e.Reason)
+	}
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *AuthError) Unwrap() error { return e.Err }
+
+func authFailure(reason AuthFailureReason, err error) *AuthError {
+	return &AuthError{Reason: reason, Err: err}
+}
+
+// failureReason classifies an error from the validator or the key-auth
+// extractor. Anything unrecognised counts as a malformed token.
+func failureReason(err error) AuthFailureReason {
+	var ae *AuthError
+	if errors.As(err, &ae) {
+		return ae.Reason
+	}
+	return ReasonMalformedToken
+}
+
+// parseFailure classifies a jwt.ParseWithClaims error. A bad signature wins
+// over claim problems, so a forged token never learns whether it had expired.
+func parseFailure(err error) *AuthError {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return authFailure(ReasonMalformedToken, err)
+	}
+	var ae *AuthError
+	switch {
+	case errors.As(ve.Inner, &ae):
+		return ae // from keyFunc
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return authFailure(ReasonMalformedToken, err)
+	case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return authFailure(ReasonInvalidSignature, err)
+	}
+	return claimsFailure(ve.Inner, err)
+}
+
+// claimsFailure classifies a models.Context.Valid error; err is what the
+// AuthError wraps.
+func claimsFailure(validErr, err error) *AuthError {
+	switch {
+	case errors.Is(validErr, models.ErrTokenExpired):
+		return authFailure(ReasonExpired, err)
+	case errors.Is(validErr, models.ErrTokenNotYetValid), errors.Is(validErr, models.ErrTokenIssuedInFuture):
+		return authFailure(ReasonNotYetValid, err)
+	}
+	return authFailure(ReasonInvalidClaims, err)
+}
+
+// formatChallenge renders a WWW-Authenticate challenge: the scheme followed by
+// the non-empty key/value pairs as quoted auth-params.
+func formatChallenge(scheme string, kv ...string) string {
+	var b strings.Builder
+	b.WriteString(scheme)
+	sep := " "
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "%s%s=%q", sep, kv[i], kv[i+1])
+		sep = ", "
+	}
+	return b.String()
+}
+
+// addChallengeParam appends an auth-param to an existing challenge.
+func addChallengeParam(challenge, key, value string) string {
+	sep := ", "
+	if !strings.Contains(challenge, " ") {
+		sep = " "
+	}
+	return fmt.Sprintf("%s%s%s=%q", challenge, sep, key, value)
+}
+
+// errorBody lets an httpErr.HTTPError be the message of an echo.HTTPError:
+// echo renders json.Marshalers as-is, so the client gets the localized body
+// while HTTPErrorHandlers still see the status code.
+type errorBody struct{ *httpErr.HTTPError }
+
+func (b errorBody) MarshalJSON() ([]byte, error) { return json.Marshal(b.HTTPError) }
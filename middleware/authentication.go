@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -12,12 +13,11 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt"
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
-	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
-	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/utils"
+	httpErr "github.com/grasp-labs/ds-go-commonmodels/v3/commonmodels/http_error"
+
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/internal/models"
@@ -28,6 +28,28 @@ type authConfig struct {
 	audience       string // this service's resource id ("" = audience check disabled)
 	sharedAudience string // additionally-accepted mesh-wide audience ("" = none)
 	useJWKS        bool   // true = resolve keys by kid from live JWKS
+	discovery      bool   // true = locate the JWKS via issuer metadata
+	algorithms     []string
+	trustedIssuers []TrustedIssuer
+	keySource      KeySource       // overrides publicKeyPEM / WithJWKS for Config.Issuer()
+	refreshCtx     context.Context // non-nil = refresh JWKS in the background until done
+	onRefreshErr   func(ctx context.Context, err error)
+
+	jwksCacheControl bool // true = honour the JWKS Cache-Control max-age
+	jwksMaxAgeMin    time.Duration
+	jwksMaxAgeMax    time.Duration
+
+	snapshotDir    string // "" = don't persist JWKS snapshots
+	snapshotMaxAge time.Duration
+
+	monitor  *JWKSMonitor  // nil = JWKS status not reported
+	prefetch *JWKSPrefetch // nil = JWKS fetched on first use
+
+	revocation    RevocationChecker // nil = revocation not checked
+	introspection *Introspection    // nil = opaque tokens rejected
+	dpop          *DPoP             // nil = plain bearer tokens
+
+	jwksOptions JWKSOptions
 }
 
 // AuthOption configures AuthenticationMiddleware.
@@ -95,43 +117,130 @@ func ParseRSAPublicKey(pemKey string) (*rsa.PublicKey, error) {
 // Pass WithAudience to enable RFC 8707 audience binding; existing callers with
 // five arguments compile unchanged and retain today's behaviour.
 func AuthenticationMiddleware(cfg interfaces.Config, logger interfaces.Logger, publicKeyPEM string, producer *adapters.ProducerAdapter, topic string, opts ...AuthOption) (echo.MiddlewareFunc, error) {
-	ac := &authConfig{}
+	ac := &authConfig{algorithms: supportedAlgorithms}
 	for _, o := range opts {
 		o(ac)
 	}
+	if err := validateAlgorithms(ac.algorithms); err != nil {
+		return nil, err
+	}
+	if err := ac.validateJWKS(logger); err != nil {
+		return nil, err
+	}
 
 	issuer := strings.TrimRight(cfg.Issuer(), "/")
 	if issuer == "" {
 		return nil, errors.New("config issuer is empty; cannot enforce iss")
 	}
+	events := NewEventBuilder(cfg)
+
+	// Resolve the trusted issuers. The configured issuer uses the
+	// middleware-wide options: either the live JWKS (rotation-safe, keyed by
+	// kid) or a single static PEM (legacy / fixed-key deployments). Each one
+	// gets its own key source, so a kid from one issuer never resolves
+	// against another's keys.
+	trusted := map[string]*issuerTrust{}
+	if !slices.ContainsFunc(ac.trustedIssuers, func(ti TrustedIssuer) bool {
+		return strings.TrimRight(ti.Issuer, "/") == issuer
+	}) {
+		primary := TrustedIssuer{
+			Issuer:         issuer,
+			Audience:       ac.audience,
+			SharedAudience: ac.sharedAudience,
+			Discovery:      ac.discovery,
+			KeySource:      ac.keySource,
+
+			JWKSURI:          ac.jwksOptions.URI,
+			JWKSFailoverURIs: ac.jwksOptions.FailoverURIs,
+		}
+		if !ac.useJWKS && ac.keySource == nil {
+			primary.PublicKeyPEM = publicKeyPEM
+			if publicKeyPEM == "" {
+				return nil, errors.New("public key PEM is empty; pass one or enable WithJWKS")
+			}
+		}
+		it, err := newIssuerTrust(primary)
+		if err != nil {
+			return nil, err
+		}
+		trusted[issuer] = it
+	}
+	for _, ti := range ac.trustedIssuers {
+		it, err := newIssuerTrust(ti)
+		if err != nil {
+			return nil, err
+		}
+		trusted[it.issuer] = it
+	}
+	var caches []*jwksCache
+	for _, it := range trusted {
+		if it.jwks != nil {
+			ac.configureJWKS(it.jwks, logger)
+			if ac.monitor != nil {
+				ac.monitor.add(it.issuer, it.jwks)
+			}
+			caches = append(caches, it.jwks)
+		}
+	}
+	if ac.prefetch != nil {
+		if err := ac.prefetchJWKS(caches, logger); err != nil {
+			return nil, err
+		}
+	}
 
-	// Resolve the verifying key source: either the live JWKS (rotation-safe,
-	// keyed by kid) or a single static PEM (legacy / fixed-key deployments).
-	var (
-		staticKey *rsa.PublicKey
-		jwks      *jwksCache
-	)
-	if ac.useJWKS {
-		jwks = newJWKSCache(issuer + jwksWellKnownSuffix)
-	} else {
+	var introspect *introspector
+	if ac.introspection != nil {
 		var err error
-		staticKey, err = ParseRSAPublicKey(publicKeyPEM)
-		if err != nil {
+		if introspect, err = newIntrospector(*ac.introspection, issuer); err != nil {
 			return nil, err
 		}
 	}
 
-	// keyFunc resolves the RSA public key for a parsed token, rejecting any
-	// non-RSA signing method (never accept alg:none / HS*).
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	var dpop *dpopVerifier
+	authScheme := "Bearer"
+	if ac.dpop != nil {
+		var err error
+		if dpop, err = newDPoPVerifier(*ac.dpop); err != nil {
+			return nil, err
 		}
-		if jwks != nil {
+		authScheme = "DPoP"
+	}
+
+	if ac.refreshCtx != nil {
+		onErr := func(ctx context.Context, err error) {
+			logger.Error(ctx, "%v", err)
+			if ac.onRefreshErr != nil {
+				ac.onRefreshErr(ctx, err)
+			}
+		}
+		for _, it := range trusted {
+			if it.jwks != nil {
+				go it.jwks.refreshLoop(ac.refreshCtx, onErr)
+			}
+		}
+	}
+
+	// keyFunc resolves the verifying key for a parsed token. The issuer is
+	// chosen from the still-unverified `iss` and only its keys are tried. The
+	// alg must be on the allowlist (never alg:none / HS*) and fit the key's
+	// type, so a key can never be used with an algorithm it was not meant for.
+	keyFunc := func(ctx context.Context) jwt.Keyfunc {
+		return func(t *jwt.Token) (interface{}, error) {
+			alg := t.Method.Alg()
+			if !slices.Contains(ac.algorithms, alg) {
+				return nil, authFailure(ReasonUnsupportedAlg, fmt.Errorf("unexpected signing method: %v", t.Header["alg"]))
+			}
+			claims, _ := t.Claims.(*models.Context)
+			if claims == nil {
+				return nil, authFailure(ReasonMalformedToken, errors.New("unexpected claims type"))
+			}
+			it, ok := trusted[claims.Iss]
+			if !ok {
+				return nil, authFailure(ReasonUntrustedIssuer, fmt.Errorf("token iss %q is not a trusted issuer", claims.Iss))
+			}
 			kid, _ := t.Header["kid"].(string)
-			return jwks.getKey(kid)
+			return it.verifyingKey(ctx, kid, alg)
 		}
-		return staticKey, nil
 	}
 
 	// Helper to normalize token (strip "Bearer " if present)
@@ -150,7 +259,7 @@ func AuthenticationMiddleware(cfg interfaces.Config, logger interfaces.Logger, p
 		KeyLookup: "header:Authorization",
 		// - If you prefer X-Api-Key, change to: KeyLookup: "header:X-Api-Key"
 		//   and set AuthScheme to "" (empty string) if the X-Api-Key header contains only the token.
-		AuthScheme: "Bearer",
+		AuthScheme: authScheme,
 
 		Skipper: func(c echo.Context) bool {
 			// Let CORS preflight pass
@@ -162,43 +271,69 @@ func AuthenticationMiddleware(cfg interfaces.Config, logger interfaces.Logger, p
 
 		Validator: func(raw string, c echo.Context) (bool, error) {
 			// Store raw authorization header in the Echo context
-			c.Set("Authorization", "Bearer "+raw)
+			c.Set("Authorization", authScheme+" "+raw)
 			token := trimBearer(raw)
 
 			if token == "" {
 				logger.Error(c.Request().Context(), "Token is empty.")
-				return false, WrapErr(c, "unauthorized")
+				return false, WrapErr(c, "unauthorized").WithCause(authFailure(ReasonMissingToken, nil))
 			}
 
 			claims := &models.Context{}
-			parsed, err := jwt.ParseWithClaims(token, claims, keyFunc)
+			if introspect != nil && isOpaque(token) {
+				// Opaque token: the authorization server vouches for the
+				// claims; the time and shape checks still apply.
+				var err error
+				if claims, err = introspect.introspect(c.Request().Context(), token); err != nil {
+					logger.Error(c.Request().Context(), "Token introspection: %v", err)
+					return false, WrapErr(c, "unauthorized").WithCause(err)
+				}
+				if err := claims.Valid(); err != nil {
+					logger.Error(c.Request().Context(), "Invalid introspected token: %v", err)
+					return false, WrapErr(c, "unauthorized").WithCause(claimsFailure(err, err))
+				}
+			} else {
+				parsed, err := jwt.ParseWithClaims(token, claims, keyFunc(c.Request().Context()))
+				if err != nil || !parsed.Valid {
+					logger.Error(c.Request().Context(), "Invalid token: %v", err)
+					return false, WrapErr(c, "unauthorized").WithCause(parseFailure(err))
+				}
+			}
 
-			if err != nil || !parsed.Valid {
-				logger.Error(c.Request().Context(), "Invalid token: %v", err)
-				return false, WrapErr(c, "unauthorized")
+			// Enforce issuer against the trusted set. The signature (or the
+			// introspection response) vouches for `iss`.
+			it, ok := trusted[claims.Iss]
+			if !ok {
+				logger.Error(c.Request().Context(), "token iss %q is not a trusted issuer", claims.Iss)
+				return false, WrapErr(c, "unauthorized").WithCause(authFailure(ReasonUntrustedIssuer, nil))
 			}
 
-			// Enforce issuer against this environment's configured issuer.
-			if claims.Iss != issuer {
-				logger.Error(c.Request().Context(), "token iss %q does not match expected issuer %q", claims.Iss, issuer)
-				return false, WrapErr(c, "unauthorized")
+			// Reject any principal kind the issuer may not mint (cls must be
+			// user|app, narrowed per issuer).
+			if err := it.checkClass(claims.Cls); err != nil {
+				logger.Error(c.Request().Context(), "%v", err)
+				return false, WrapErr(c, "unauthorized").WithCause(err)
 			}
 
-			// Reject any unrecognized principal kind (cls must be user|app).
-			if !requestctx.ValidKind(claims.Cls) {
-				logger.Error(c.Request().Context(), "token has invalid cls: %q", claims.Cls)
-				return false, WrapErr(c, "unauthorized")
+			// Audience check (RFC 8707) — only when enabled for the issuer
+			// (WithAudience and/or WithSharedAudience). Set-membership: accept
+			// if `aud` contains this service's resource id OR the mesh-wide
+			// shared audience.
+			if err := it.checkAudience(claims.Aud); err != nil {
+				logger.Error(c.Request().Context(), "%v", err)
+				return false, WrapErr(c, "unauthorized").WithCause(err)
 			}
 
-			// Audience check (RFC 8707) — only when enabled (WithAudience and/or
-			// WithSharedAudience). Set-membership: accept if `aud` contains this
-			// service's resource id OR the mesh-wide shared audience.
-			if ac.audience != "" || ac.sharedAudience != "" {
-				accepted := (ac.audience != "" && slices.Contains(claims.Aud, ac.audience)) ||
-					(ac.sharedAudience != "" && slices.Contains(claims.Aud, ac.sharedAudience))
-				if !accepted {
-					logger.Error(c.Request().Context(), "token aud %v missing resource %q / shared %q", claims.Aud, ac.audience, ac.sharedAudience)
-					return false, WrapErr(c, "unauthorized")
+			// Proof of possession (RFC 9449): the caller must hold the key
+			// the token is bound to.
+			if dpop != nil {
+				var jkt string
+				if claims.Cnf != nil {
+					jkt = claims.Cnf.Jkt
+				}
+				if err := dpop.verify(c, token, jkt); err != nil {
+					logger.Error(c.Request().Context(), "%v", err)
+					return false, WrapErr(c, "unauthorized").WithCause(err)
 				}
 			}
 
@@ -206,7 +341,30 @@ func AuthenticationMiddleware(cfg interfaces.Config, logger interfaces.Logger, p
 			principal, err := requestctx.NewPrincipal(claims)
 			if err != nil {
 				logger.Error(c.Request().Context(), "Invalid tenant_id from claims: %s", claims.Rsc)
-				return false, WrapErr(c, "unauthorized")
+				return false, WrapErr(c, "unauthorized").WithCause(authFailure(ReasonInvalidClaims, err))
+			}
+
+			// Reject revoked tokens (offboarded users, leaked app secrets)
+			// before the request sees the principal.
+			if ac.revocation != nil {
+				tok := TokenInfo{
+					JTI:      principal.JTI,
+					Subject:  principal.ID,
+					TenantID: principal.TenantID,
+					Issuer:   principal.Issuer,
+				}
+				if claims.Iat != 0 {
+					tok.IssuedAt = time.Unix(int64(claims.Iat), 0)
+				}
+				revoked, err := ac.revocation.IsRevoked(c.Request().Context(), tok)
+				if err != nil {
+					logger.Error(c.Request().Context(), "revocation check for jti %s: %v", principal.JTI, err)
+					return false, WrapErr(c, "unauthorized").WithCause(authFailure(ReasonRevocationFailed, err))
+				}
+				if revoked {
+					logger.Error(c.Request().Context(), "token jti %s of %s is revoked", principal.JTI, principal.ID)
+					return false, WrapErr(c, "unauthorized").WithCause(authFailure(ReasonRevoked, nil))
+				}
 			}
 
 			// Stash claims in Echo context (typed key) and standard context
@@ -219,8 +377,6 @@ func AuthenticationMiddleware(cfg interfaces.Config, logger interfaces.Logger, p
 			c.SetRequest(c.Request().WithContext(ctx))
 
 			// Should send Login Succeeded event
-			requestID := requestctx.GetOrNewRequestUUID(c.Request().Context())
-			sessionID := requestctx.GetOrNewSessionUUID(c.Request().Context())
 
 			// Optional message from header
 			var message *string
@@ -228,68 +384,70 @@ func AuthenticationMiddleware(cfg interfaces.Config, logger interfaces.Logger, p
 				message = &val
 			}
 
-			event := sdkmodels.EventJson{
-				Id:          uuid.New(),
-				TenantId:    principal.TenantID,
-				RequestId:   requestID,
-				SessionId:   sessionID,
-				EventType:   "login.success", // Check this
-				EventSource: utils.CreateServicePrincipleID(cfg),
-				Timestamp:   time.Now().UTC(),
-				Message:     message,
-				Payload: &map[string]any{
-					"subject":     claims.Sub,
-					"cls":         principal.Kind,
-					"jti":         claims.Jti.String(),
-					"tenant_id":   principal.TenantID.String(),
-					"path":        c.Path(),
-					"user_agent":  c.Request().UserAgent(),
-					"remote_addr": c.Request().RemoteAddr,
-				},
-			}
-
-			sendEventAsync(ctx, producer, logger, topic, event, "login.success")
+			event := events.Build(ctx, EventTypeLoginSuccess, LoginPayload{
+				SchemaVersion: PayloadSchemaVersion,
+				Subject:       claims.Sub,
+				Cls:           principal.Kind,
+				Jti:           claims.Jti.String(),
+				TenantID:      principal.TenantID,
+				Path:          c.Path(),
+				UserAgent:     c.Request().UserAgent(),
+				RemoteAddr:    c.Request().RemoteAddr,
+			}, WithEventMessage(message))
+
+			sendEventAsync(ctx, producer, logger, topic, event, EventTypeLoginSuccess)
 			return true, nil
 		},
 		ErrorHandler: func(handlerErr error, c echo.Context) error {
 			reqCtx := c.Request().Context()
 			logger.Error(c.Request().Context(), "Jwt error: %v", handlerErr)
 
-			// Attach the RFC 6750 challenge to the 401. When this service has a
-			// resource id (WithAudience), point at its PRM document; otherwise a
-			// bare Bearer challenge.
+			// A request without a token gets no error code (RFC 6750 §3.1);
+			// anything else is an invalid_token with a reason-specific
+			// description.
+			reason := ReasonMissingToken
+			var missing *middleware.ErrKeyAuthMissing
+			if !errors.As(handlerErr, &missing) {
+				reason = failureReason(handlerErr)
+			}
+
+			// Attach the RFC 6750 challenge (RFC 9449 with WithDPoP) to the
+			// 401. When this service has a resource id (WithAudience), also
+			// point at its PRM document.
 			if !c.Response().Committed {
-				challenge := "Bearer"
+				var resourceMetadata, algs string
 				if ac.audience != "" {
-					challenge = fmt.Sprintf("Bearer resource_metadata=%q", ac.audience+WellKnownProtectedResourcePath)
+					resourceMetadata = ac.audience + WellKnownProtectedResourcePath
 				}
-				c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
-			}
-
-			requestIDStr := requestctx.GetRequestID(c.Request().Context())
-			requestID, parseErr := uuid.Parse(requestIDStr)
-			if parseErr != nil {
-				logger.Error(c.Request().Context(), "Invalid request_id from context: %v", parseErr)
-				requestID = uuid.New()
+				scheme := "Bearer"
+				if dpop != nil {
+					scheme, algs = "DPoP", strings.Join(dpop.algorithms, " ")
+				}
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, formatChallenge(scheme,
+					"resource_metadata", resourceMetadata,
+					"algs", algs,
+					"error", reason.errorCode(),
+					"error_description", reason.description(),
+				))
 			}
 
-			event := sdkmodels.EventJson{
-				Id:          requestID,
-				TenantId:    uuid.UUID{},
-				EventType:   "login.failure", // Check this
-				EventSource: utils.CreateServicePrincipleID(cfg),
-				Timestamp:   time.Now().UTC(),
-				Payload: &map[string]any{
-					"subject":     "",
-					"path":        c.Path(),
-					"user_agent":  c.Request().UserAgent(),
-					"remote_addr": c.Request().RemoteAddr,
-				},
+			event := events.Build(reqCtx, EventTypeLoginFailure, LoginPayload{
+				SchemaVersion: PayloadSchemaVersion,
+				Reason:        reason,
+				Path:          c.Path(),
+				UserAgent:     c.Request().UserAgent(),
+				RemoteAddr:    c.Request().RemoteAddr,
+			})
+
+			sendEventAsync(reqCtx, producer, logger, topic, event, EventTypeLoginFailure)
+
+			// Keep the localized body built by the validator; requests
+			// without a token get a fresh one.
+			var body *httpErr.HTTPError
+			if !errors.As(handlerErr, &body) {
+				body = WrapErr(c, "unauthorized").WithCause(authFailure(reason, handlerErr))
 			}
-
-			sendEventAsync(reqCtx, producer, logger, topic, event, "login.failure")
-
-			return echo.ErrUnauthorized
+			return &echo.HTTPError{Code: http.StatusUnauthorized, Message: errorBody{body}, Internal: body}
 		},
 	}), nil
 }
@@ -0,0 +1,157 @@
+package middleware_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+// publicJWK encodes an RSA, ECDSA or Ed25519 public key as a JWK map.
+func publicJWK(t *testing.T, kid, alg string, pub any) map[string]string {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	out := map[string]string{"kid": kid, "use": "sig"}
+	if alg != "" {
+		out["alg"] = alg
+	}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		out["kty"], out["n"], out["e"] = "RSA", b64(k.N.Bytes()), b64(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		out["kty"], out["crv"] = "EC", k.Curve.Params().Name
+		out["x"], out["y"] = b64(k.X.FillBytes(make([]byte, size))), b64(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		out["kty"], out["crv"], out["x"] = "OKP", "Ed25519", b64(k)
+	default:
+		t.Fatalf("unsupported key %T", pub)
+	}
+	return out
+}
+
+// newJWKSIssuer serves keys at the issuer's JWKS path and returns its URL.
+func newJWKSIssuer(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func mustECKey(t *testing.T, c elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(c, rand.Reader)
+	require.NoError(t, err)
+	return k
+}
+
+func mustEdKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return k
+}
+
+func TestAuthN_JWKS_AcceptsECAndEdDSA(t *testing.T) {
+	p256, p384, ed := mustECKey(t, elliptic.P256()), mustECKey(t, elliptic.P384()), mustEdKey(t)
+	iss := newJWKSIssuer(t,
+		publicJWK(t, "ec256", "ES256", &p256.PublicKey),
+		publicJWK(t, "ec384", "", &p384.PublicKey),
+		publicJWK(t, "ed", "EdDSA", ed.Public()),
+	)
+	e := newAuthApp(t, "", iss, middleware.WithJWKS())
+
+	for name, tok := range map[string]string{
+		"ES256": mintTokenWith(t, jwt.SigningMethodES256, p256, tokenOpts{iss: iss, cls: "user", kid: "ec256"}),
+		"ES384": mintTokenWith(t, jwt.SigningMethodES384, p384, tokenOpts{iss: iss, cls: "user", kid: "ec384"}),
+		"EdDSA": mintTokenWith(t, jwt.SigningMethodEdDSA, ed, tokenOpts{iss: iss, cls: "app", kid: "ed"}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+		})
+	}
+}
+
+func TestAuthN_JWKS_RejectsKeyTypeOrAlgMismatch(t *testing.T) {
+	rsaKey, _, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	p256 := mustECKey(t, elliptic.P256())
+	iss := newJWKSIssuer(t,
+		publicJWK(t, "ec", "", &p256.PublicKey),
+		publicJWK(t, "rsa-ps", "PS256", &rsaKey.PublicKey), // declared alg doesn't fit: skipped
+		publicJWK(t, "rsa", "RS256", &rsaKey.PublicKey),
+	)
+	e := newAuthApp(t, "", iss, middleware.WithJWKS())
+
+	tok := mintToken(t, rsaKey, tokenOpts{iss: iss, cls: "user", kid: "rsa"})
+	require.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+
+	// RS256 token pointing at an EC key.
+	tok = mintToken(t, rsaKey, tokenOpts{iss: iss, cls: "user", kid: "ec"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+
+	// RS384 token pointing at a key declared RS256.
+	tok = mintTokenWith(t, jwt.SigningMethodRS384, rsaKey, tokenOpts{iss: iss, cls: "user", kid: "rsa"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+
+	tok = mintToken(t, rsaKey, tokenOpts{iss: iss, cls: "user", kid: "rsa-ps"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+}
+
+func TestAuthN_StaticECKey(t *testing.T) {
+	p384 := mustECKey(t, elliptic.P384())
+	pubPEM, err := fakes.PublicKeyPEM(&p384.PublicKey)
+	require.NoError(t, err)
+	e := newAuthApp(t, pubPEM, testIssuer)
+
+	tok := mintTokenWith(t, jwt.SigningMethodES384, p384, tokenOpts{cls: "user"})
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+}
+
+func TestAuthN_RejectsHMACAndNone(t *testing.T) {
+	_, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	e := newAuthApp(t, pubPEM, testIssuer)
+
+	// Classic confusion attack: HS256 keyed with the public key bytes.
+	tok := mintTokenWith(t, jwt.SigningMethodHS256, []byte(pubPEM), tokenOpts{cls: "user"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+
+	tok = mintTokenWith(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, tokenOpts{cls: "user"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+}
+
+func TestAuthN_AlgorithmAllowlist(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+
+	e := newAuthApp(t, pubPEM, testIssuer, middleware.WithAlgorithms(middleware.AlgES256))
+	tok := mintToken(t, priv, tokenOpts{cls: "user"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", [16]byte{}, 512)
+	cfg.SetIssuer(testIssuer)
+	for _, algs := range [][]string{{"HS256"}, {"none"}, {"PS256"}, {}} {
+		_, err := middleware.AuthenticationMiddleware(cfg, &fakes.MockLogger{}, pubPEM, nil, "t", middleware.WithAlgorithms(algs...))
+		assert.Error(t, err, "allowlist %v", algs)
+	}
+}
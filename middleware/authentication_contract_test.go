@@ -33,9 +33,17 @@ type tokenOpts struct {
 	exp time.Time
 	nbf time.Time
 	iat time.Time
+
+	extra map[string]any // additional claims
 }
 
 func mintToken(t *testing.T, priv *rsa.PrivateKey, o tokenOpts) string {
+	t.Helper()
+	return mintTokenWith(t, jwt.SigningMethodRS256, priv, o)
+}
+
+// mintTokenWith is mintToken for any signing method and matching private key.
+func mintTokenWith(t *testing.T, method jwt.SigningMethod, priv any, o tokenOpts) string {
 	t.Helper()
 	now := time.Now()
 	if o.iss == "" {
@@ -70,8 +78,11 @@ func mintToken(t *testing.T, priv *rsa.PrivateKey, o tokenOpts) string {
 	if o.cls != "" {
 		claims["cls"] = o.cls
 	}
+	for k, v := range o.extra {
+		claims[k] = v
+	}
 
-	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok := jwt.NewWithClaims(method, claims)
 	if o.kid != "" {
 		tok.Header["kid"] = o.kid
 	}
@@ -104,6 +115,11 @@ func newAuthApp(t *testing.T, pubPEM, issuer string, opts ...middleware.AuthOpti
 			"kind":   p.Kind,
 			"id":     p.ID,
 			"tenant": p.TenantID.String(),
+			"issuer": p.Issuer,
+			"authorization": func() string {
+				v, _ := c.Get("Authorization").(string)
+				return v
+			}(),
 		})
 	})
 	return e
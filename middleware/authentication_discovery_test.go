@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+// discoveryServer serves issuer metadata at metadataPath pointing at a JWKS on
+// a non-default path. Set advertisedIss to publish a different issuer.
+type discoveryServer struct {
+	url           string
+	metadataHits  atomic.Int32
+	advertisedIss string
+}
+
+func newDiscoveryServer(t *testing.T, metadataPath string, algs []string, keys ...map[string]string) *discoveryServer {
+	t.Helper()
+	ds := &discoveryServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(metadataPath, func(w http.ResponseWriter, r *http.Request) {
+		ds.metadataHits.Add(1)
+		iss := ds.advertisedIss
+		if iss == "" {
+			iss = ds.url
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                iss,
+			"jwks_uri":                              ds.url + "/keys",
+			"id_token_signing_alg_values_supported": algs,
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	ds.url = srv.URL
+	return ds
+}
+
+func TestAuthN_Discovery_UsesAdvertisedJWKSURI(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	jwk := publicJWK(t, "k", "ES256", &key.PublicKey)
+
+	for _, path := range []string{middleware.OIDCDiscoveryPath, middleware.OAuthServerMetadataPath} {
+		t.Run(path, func(t *testing.T) {
+			ds := newDiscoveryServer(t, path, nil, jwk)
+			e := newAuthApp(t, "", ds.url, middleware.WithDiscovery())
+
+			for range 3 {
+				tok := mintTokenWith(t, jwt.SigningMethodES256, key, tokenOpts{iss: ds.url, cls: "user", kid: "k"})
+				assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+			}
+			assert.Equal(t, int32(1), ds.metadataHits.Load(), "metadata is cached")
+		})
+	}
+}
+
+func TestAuthN_Discovery_RejectsIssuerMismatch(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	ds := newDiscoveryServer(t, middleware.OIDCDiscoveryPath, nil, publicJWK(t, "k", "ES256", &key.PublicKey))
+	ds.advertisedIss = "https://attacker.example.com"
+	e := newAuthApp(t, "", ds.url, middleware.WithDiscovery())
+
+	tok := mintTokenWith(t, jwt.SigningMethodES256, key, tokenOpts{iss: ds.url, cls: "user", kid: "k"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+}
+
+func TestAuthN_Discovery_NarrowsAlgorithms(t *testing.T) {
+	rsaKey, _, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	ecKey := mustECKey(t, elliptic.P256())
+	ds := newDiscoveryServer(t, middleware.OIDCDiscoveryPath, []string{"ES256"},
+		publicJWK(t, "ec", "", &ecKey.PublicKey),
+		publicJWK(t, "rsa", "", &rsaKey.PublicKey),
+	)
+	e := newAuthApp(t, "", ds.url, middleware.WithDiscovery())
+
+	tok := mintTokenWith(t, jwt.SigningMethodES256, ecKey, tokenOpts{iss: ds.url, cls: "user", kid: "ec"})
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+
+	tok = mintToken(t, rsaKey, tokenOpts{iss: ds.url, cls: "user", kid: "rsa"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code, "RS256 not advertised")
+}
@@ -0,0 +1,118 @@
+package middleware_test
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+)
+
+// newAuthAppWithProducer is newAuthApp exposing the producer, for tests that
+// inspect the emitted login events.
+func newAuthAppWithProducer(t *testing.T, pubPEM string, opts ...middleware.AuthOption) (*echo.Echo, *fakes.MockProducer) {
+	t.Helper()
+	e := echo.New()
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", uuid.New(), 512)
+	cfg.SetIssuer(testIssuer)
+	mock := &fakes.MockProducer{}
+	producer := &adapters.ProducerAdapter{Producer: mock}
+
+	authMW, err := middleware.AuthenticationMiddleware(cfg, &fakes.MockLogger{}, pubPEM, producer, "ds.test.v1", opts...)
+	require.NoError(t, err)
+	e.Use(authMW)
+	e.GET("/protected/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	return e, mock
+}
+
+func TestAuthN_FailureReasons(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	otherPriv, _, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	ec := mustECKey(t, elliptic.P256())
+
+	cases := []struct {
+		name   string
+		token  string
+		reason middleware.AuthFailureReason
+	}{
+		{"missing", "", middleware.ReasonMissingToken},
+		{"malformed", "not-a-jwt", middleware.ReasonMalformedToken},
+		{"expired", mintToken(t, priv, tokenOpts{cls: "user", exp: time.Now().Add(-5 * time.Minute)}), middleware.ReasonExpired},
+		{"not yet valid", mintToken(t, priv, tokenOpts{cls: "user", nbf: time.Now().Add(5 * time.Minute)}), middleware.ReasonNotYetValid},
+		{"bad signature", mintToken(t, otherPriv, tokenOpts{cls: "user"}), middleware.ReasonInvalidSignature},
+		{"expired with bad signature", mintToken(t, otherPriv, tokenOpts{cls: "user", exp: time.Now().Add(-5 * time.Minute)}), middleware.ReasonInvalidSignature},
+		{"alg not allowed", mintTokenWith(t, jwt.SigningMethodES256, ec, tokenOpts{cls: "user"}), middleware.ReasonUnsupportedAlg},
+		{"untrusted issuer", mintToken(t, priv, tokenOpts{iss: "https://evil.example.com", cls: "user"}), middleware.ReasonUntrustedIssuer},
+		{"wrong audience", mintToken(t, priv, tokenOpts{cls: "user", aud: []string{"https://other.example.com"}}), middleware.ReasonInvalidAudience},
+		{"bad cls", mintToken(t, priv, tokenOpts{cls: "robot"}), middleware.ReasonInvalidClass},
+		{"bad rsc", mintToken(t, priv, tokenOpts{cls: "user", rsc: "nope"}), middleware.ReasonInvalidClaims},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, mock := newAuthAppWithProducer(t, pubPEM,
+				middleware.WithAudience(testResource), middleware.WithAlgorithms(middleware.AlgRS256))
+			rec := doGet(t, e, "/protected/", tc.token)
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+			challenge := rec.Header().Get("WWW-Authenticate")
+			assert.Contains(t, challenge, `resource_metadata="`+testResource+middleware.WellKnownProtectedResourcePath+`"`)
+			if tc.reason == middleware.ReasonMissingToken {
+				assert.NotContains(t, challenge, "error=")
+			} else {
+				assert.Contains(t, challenge, `error="invalid_token", error_description="`)
+			}
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, "unauthorized", body["code"])
+			assert.NotEmpty(t, body["message"])
+
+			require.True(t, mock.WaitForSend(time.Second))
+			ev, ok := mock.Value().(sdkmodels.EventJson)
+			require.True(t, ok)
+			payload, err := middleware.DecodeLoginPayload(ev)
+			require.NoError(t, err)
+			assert.Equal(t, tc.reason, payload.Reason)
+		})
+	}
+}
+
+func TestAuthN_UnknownKidReason(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	iss := newJWKSIssuer(t, publicJWK(t, "known", "ES256", &key.PublicKey))
+	e := newAuthApp(t, "", iss, middleware.WithJWKS())
+
+	tok := mintTokenWith(t, jwt.SigningMethodES256, key, tokenOpts{iss: iss, cls: "user", kid: "unknown"})
+	rec := doGet(t, e, "/protected/", tok)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer error="invalid_token", error_description="The access token signing key is unknown"`,
+		rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestRegisterProtectedResource_KeepsAuthChallenge(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	e := newAuthApp(t, pubPEM, testIssuer)
+	middleware.RegisterProtectedResource(e, "", middleware.ResourceMetadata{Resource: testResource})
+
+	tok := mintToken(t, priv, tokenOpts{cls: "user", exp: time.Now().Add(-5 * time.Minute)})
+	rec := doGet(t, e, "/protected/", tok)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	want := `Bearer error="invalid_token", error_description="The access token expired", resource_metadata="` +
+		testResource + middleware.WellKnownProtectedResourcePath + `"`
+	assert.Equal(t, want, rec.Header().Get("WWW-Authenticate"))
+}
@@ -0,0 +1,104 @@
+package middleware_test
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+func TestAuthN_TrustedIssuers_EachVerifiedWithOwnKeys(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	partnerKey := mustECKey(t, elliptic.P256())
+	partner := newJWKSIssuer(t, publicJWK(t, "p1", "ES256", &partnerKey.PublicKey))
+
+	e := newAuthApp(t, pubPEM, testIssuer, middleware.WithTrustedIssuers(middleware.TrustedIssuer{Issuer: partner + "/"}))
+
+	for iss, tok := range map[string]string{
+		testIssuer: mintToken(t, priv, tokenOpts{cls: "user"}),
+		partner:    mintTokenWith(t, jwt.SigningMethodES256, partnerKey, tokenOpts{iss: partner, cls: "user", kid: "p1"}),
+	} {
+		rec := doGet(t, e, "/me", tok)
+		require.Equal(t, http.StatusOK, rec.Code, iss)
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, iss, body["issuer"])
+	}
+
+	// The partner's key must not vouch for the primary issuer, and vice versa.
+	tok := mintTokenWith(t, jwt.SigningMethodES256, partnerKey, tokenOpts{cls: "user", kid: "p1"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+	tok = mintToken(t, priv, tokenOpts{iss: partner, cls: "user", kid: "p1"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+
+	tok = mintToken(t, priv, tokenOpts{iss: "https://evil.example.com", cls: "user"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+}
+
+func TestAuthN_TrustedIssuers_PerIssuerAudienceAndClasses(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	partnerPriv, partnerPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	const partner = "https://idp.partner.example.com"
+
+	e := newAuthApp(t, pubPEM, testIssuer, middleware.WithTrustedIssuers(middleware.TrustedIssuer{
+		Issuer:       partner,
+		PublicKeyPEM: partnerPEM,
+		Audience:     testResource,
+		Classes:      []string{"app"},
+	}))
+
+	// Primary issuer: no audience rule, both kinds.
+	tok := mintToken(t, priv, tokenOpts{cls: "user"})
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+
+	tok = mintToken(t, partnerPriv, tokenOpts{iss: partner, cls: "app", aud: []string{testResource}})
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+
+	tok = mintToken(t, partnerPriv, tokenOpts{iss: partner, cls: "user", aud: []string{testResource}})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code, "cls not allowed for partner")
+
+	tok = mintToken(t, partnerPriv, tokenOpts{iss: partner, cls: "app"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code, "partner aud missing")
+}
+
+func TestAuthN_TrustedIssuers_EntryReplacesPrimaryRules(t *testing.T) {
+	key := mustECKey(t, elliptic.P384())
+	iss := newJWKSIssuer(t, publicJWK(t, "k", "ES384", &key.PublicKey))
+
+	// No PEM and no WithJWKS: the entry for the configured issuer supplies keys.
+	e := newAuthApp(t, "", iss, middleware.WithTrustedIssuers(middleware.TrustedIssuer{
+		Issuer:  iss,
+		Classes: []string{"user"},
+	}))
+
+	tok := mintTokenWith(t, jwt.SigningMethodES384, key, tokenOpts{iss: iss, cls: "user", kid: "k"})
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+	tok = mintTokenWith(t, jwt.SigningMethodES384, key, tokenOpts{iss: iss, cls: "app", kid: "k"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+}
+
+func TestAuthN_TrustedIssuers_InvalidConfig(t *testing.T) {
+	_, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", [16]byte{}, 512)
+	cfg.SetIssuer(testIssuer)
+
+	for name, ti := range map[string]middleware.TrustedIssuer{
+		"empty issuer": {},
+		"unknown cls":  {Issuer: "https://idp.example.com", Classes: []string{"robot"}},
+		"bad pem":      {Issuer: "https://idp.example.com", PublicKeyPEM: "nope"},
+	} {
+		_, err := middleware.AuthenticationMiddleware(cfg, &fakes.MockLogger{}, pubPEM, nil, "t", middleware.WithTrustedIssuers(ti))
+		assert.Error(t, err, name)
+	}
+}
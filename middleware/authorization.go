@@ -12,13 +12,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 
-	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
 	"github.com/grasp-labs/ds-go-commonmodels/v3/commonmodels/entitlement"
-	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/utils"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/internal/models"
-	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
 )
 
 const adminGroup = "users.admins"
@@ -26,6 +23,8 @@ const adminGroup = "users.admins"
 // AuthorizationMiddleware for asserting a user is permitted
 // to perform action.
 func AuthorizationMiddleware(cfg interfaces.Config, logger interfaces.Logger, roles []string, url string, producer *adapters.ProducerAdapter, topic string) echo.MiddlewareFunc {
+	events := NewEventBuilder(cfg)
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			ctx := c.Request().Context()
@@ -34,12 +33,12 @@ func AuthorizationMiddleware(cfg interfaces.Config, logger interfaces.Logger, ro
 			// Get userContext from Echo context
 			userContext := c.Get("userContext")
 			if userContext == nil {
-				return errorHandler(c, &cfg, http.StatusUnauthorized, "User context not found", nil, logger, producer, "authz.denied", claims, topic)
+				return errorHandler(c, events, http.StatusUnauthorized, "User context not found", nil, logger, producer, EventTypeAuthzDenied, claims, topic)
 			}
 
 			claims, ok := userContext.(*models.Context)
 			if !ok {
-				return errorHandler(c, &cfg, http.StatusUnauthorized, "Invalid user context type", nil, logger, producer, "authz.denied", claims, topic)
+				return errorHandler(c, events, http.StatusUnauthorized, "Invalid user context type", nil, logger, producer, EventTypeAuthzDenied, claims, topic)
 			}
 
 			// Get token from Echo Context set by Authorization middleware
@@ -47,7 +46,7 @@ func AuthorizationMiddleware(cfg interfaces.Config, logger interfaces.Logger, ro
 			// Safely assert the value to a string
 			authToken, ok := authorization.(string)
 			if !ok {
-				return errorHandler(c, &cfg, http.StatusUnauthorized, "Failed to assert authorization as string", nil, logger, producer, "authz.denied", claims, topic)
+				return errorHandler(c, events, http.StatusUnauthorized, "Failed to assert authorization as string", nil, logger, producer, EventTypeAuthzDenied, claims, topic)
 
 			}
 
@@ -68,7 +67,7 @@ func AuthorizationMiddleware(cfg interfaces.Config, logger interfaces.Logger, ro
 			startTime := time.Now().UTC()
 			req, err := http.NewRequest("GET", url, nil)
 			if err != nil {
-				return errorHandler(c, &cfg, http.StatusInternalServerError, "Failed to create request to entitlement API", err, logger, producer, "authz.error", claims, topic)
+				return errorHandler(c, events, http.StatusInternalServerError, "Failed to create request to entitlement API", err, logger, producer, EventTypeAuthzError, claims, topic)
 			}
 			req.Header.Set("Authorization", authToken)
 
@@ -77,9 +76,9 @@ func AuthorizationMiddleware(cfg interfaces.Config, logger interfaces.Logger, ro
 			if err != nil {
 				var urlErr *neturl.Error
 				if errors.As(err, &urlErr) && urlErr.Timeout() {
-					return errorHandler(c, &cfg, http.StatusBadGateway, "Entitlement API request timed out", err, logger, producer, "authz.error", claims, topic)
+					return errorHandler(c, events, http.StatusBadGateway, "Entitlement API request timed out", err, logger, producer, EventTypeAuthzError, claims, topic)
 				}
-				return errorHandler(c, &cfg, http.StatusInternalServerError, "Failed to make request to Entitlement API", err, logger, producer, "authz.error", claims, topic)
+				return errorHandler(c, events, http.StatusInternalServerError, "Failed to make request to Entitlement API", err, logger, producer, EventTypeAuthzError, claims, topic)
 			}
 
 			defer func() { _ = resp.Body.Close() }()
@@ -89,11 +88,11 @@ func AuthorizationMiddleware(cfg interfaces.Config, logger interfaces.Logger, ro
 
 			body, err := io.ReadAll(resp.Body)
 			if err != nil {
-				return errorHandler(c, &cfg, http.StatusInternalServerError, "Failed to read response body from Entitlements API", err, logger, producer, "authz.error", claims, topic)
+				return errorHandler(c, events, http.StatusInternalServerError, "Failed to read response body from Entitlements API", err, logger, producer, EventTypeAuthzError, claims, topic)
 			}
 
 			if resp.StatusCode != http.StatusOK {
-				return errorHandler(c, &cfg, http.StatusUnauthorized, "Entitlements refused request", nil, logger, producer, "authz.denied", claims, topic)
+				return errorHandler(c, events, http.StatusUnauthorized, "Entitlements refused request", nil, logger, producer, EventTypeAuthzDenied, claims, topic)
 			}
 
 			// Cache result
@@ -103,7 +102,7 @@ func AuthorizationMiddleware(cfg interfaces.Config, logger interfaces.Logger, ro
 			}
 
 			if !isGranted(ctx, logger, body, roles) {
-				return errorHandler(c, &cfg, http.StatusForbidden, "Permission denied", nil, logger, producer, "authz.denied", claims, topic)
+				return errorHandler(c, events, http.StatusForbidden, "Permission denied", nil, logger, producer, EventTypeAuthzDenied, claims, topic)
 			}
 
 			logger.Info(ctx, "Entitlement accepts request for user: %s", userID)
@@ -139,7 +138,7 @@ func isGranted(ctx context.Context, logger interfaces.Logger, responseBody []byt
 
 func errorHandler(
 	c echo.Context,
-	cfg *interfaces.Config,
+	events *EventBuilder,
 	status_code int,
 	errMessage string,
 	err error,
@@ -158,10 +157,6 @@ func errorHandler(
 		logger.Error(ctx, "%s", errMessage)
 	}
 
-	// Parse (or generate) request ID set byt RequestID middleware
-	requestID := requestctx.GetOrNewRequestUUID(ctx)
-	sessionID := requestctx.GetOrNewSessionUUID(ctx)
-
 	tenantID, err := claims.GetTenantId()
 	if err != nil {
 		tenantID = uuid.UUID{}
@@ -173,24 +168,15 @@ func errorHandler(
 		message = &val
 	}
 
-	event := sdkmodels.EventJson{
-		Id:          uuid.New(),
-		TenantId:    tenantID,
-		RequestId:   requestID,
-		SessionId:   sessionID,
-		EventType:   eventType,
-		EventSource: utils.CreateServicePrincipleID(*cfg),
-		Timestamp:   time.Now().UTC(),
-		Message:     message,
-		Payload: &map[string]any{
-			"status_code": status_code,
-			"subject":     claims.Sub,
-			"error":       safeErr(err),
-			"path":        c.Path(),
-			"user_agent":  req.UserAgent(),
-			"remote_addr": req.RemoteAddr,
-		},
-	}
+	event := events.Build(ctx, eventType, AuthzPayload{
+		SchemaVersion: PayloadSchemaVersion,
+		StatusCode:    status_code,
+		Subject:       claims.Sub,
+		Error:         safeErr(err),
+		Path:          c.Path(),
+		UserAgent:     req.UserAgent(),
+		RemoteAddr:    req.RemoteAddr,
+	}, WithEventTenant(tenantID), WithEventMessage(message))
 
 	sendEventAsync(ctx, producer, logger, topic, event, eventType)
 
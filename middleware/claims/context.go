@@ -4,3 +4,6 @@ import internal "github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/inter
 
 // Re-export the internal type so other modules can use it safely.
 type Context = internal.Context
+
+// Confirmation is the `cnf` claim of a DPoP-bound token.
+type Confirmation = internal.Confirmation
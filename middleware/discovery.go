@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Well-known metadata paths tried, in order, by WithDiscovery.
+const (
+	OIDCDiscoveryPath       = "/.well-known/openid-configuration"       // OpenID Connect Discovery 1.0
+	OAuthServerMetadataPath = "/.well-known/oauth-authorization-server" // RFC 8414
+)
+
+// discoveryDefaultTTL bounds how long issuer metadata is reused. The metadata
+// changes far less often than keys, so it only needs refreshing occasionally.
+const discoveryDefaultTTL = time.Hour
+
+// WithDiscovery resolves the JWKS location from the issuer's published
+// metadata instead of the fixed {Config.Issuer()}/oauth/.well-known/jwks.json:
+// the OpenID Connect document at {issuer}/.well-known/openid-configuration is
+// tried first, then the RFC 8414 oauth-authorization-server document. The
+// document's `issuer` must equal Config.Issuer(); its `jwks_uri` is used for
+// key lookup and, when present, `id_token_signing_alg_values_supported`
+// further narrows the accepted algorithms. Implies WithJWKS.
+func WithDiscovery() AuthOption {
+	return func(a *authConfig) {
+		a.useJWKS = true
+		a.discovery = true
+	}
+}
+
+// authServerMetadata is the subset of the issuer metadata the middleware uses.
+type authServerMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// discoveryCache fetches and caches an issuer's metadata. A failed refresh
+// keeps the last good document, matching the JWKS serve-stale behaviour.
+type discoveryCache struct {
+	issuer string
+	ttl    time.Duration
+	client *http.Client
+
+	mu        sync.Mutex
+	meta      *authServerMetadata
+	fetchedAt time.Time
+}
+
+func newDiscoveryCache(issuer string) *discoveryCache {
+	return &discoveryCache{
+		issuer: issuer,
+		ttl:    discoveryDefaultTTL,
+		client: &http.Client{Timeout: jwksHTTPTimeout},
+	}
+}
+
+// jwksURI returns the discovered jwks_uri, refreshing the metadata when it is
+// missing or older than the TTL. Callers are expected to be rate limited
+// already (the JWKS refresh path is cooldown-gated).
+func (d *discoveryCache) jwksURI(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	meta, fresh := d.meta, d.meta != nil && time.Since(d.fetchedAt) < d.ttl
+	d.mu.Unlock()
+	if fresh {
+		return meta.JWKSURI, nil
+	}
+
+	got, err := d.fetch(ctx)
+	if err != nil {
+		if meta != nil {
+			return meta.JWKSURI, nil
+		}
+		return "", err
+	}
+	d.mu.Lock()
+	d.meta, d.fetchedAt = got, time.Now()
+	d.mu.Unlock()
+	return got.JWKSURI, nil
+}
+
+// algorithms returns the advertised signing algorithms from the cached
+// metadata, or nil when none are known. It never touches the network.
+func (d *discoveryCache) algorithms() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.meta == nil {
+		return nil
+	}
+	return d.meta.IDTokenSigningAlgValuesSupported
+}
+
+// fetch tries each metadata location in turn and returns the first valid
+// document.
+func (d *discoveryCache) fetch(ctx context.Context) (*authServerMetadata, error) {
+	var errs []error
+	for _, u := range discoveryURLs(d.issuer) {
+		meta, err := d.fetchOne(ctx, u)
+		if err == nil {
+			return meta, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("issuer discovery failed: %w", errors.Join(errs...))
+}
+
+func (d *discoveryCache) fetchOne(ctx context.Context, u string) (*authServerMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", u, resp.StatusCode)
+	}
+	var meta authServerMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	// The document must describe the issuer we trust (OIDC Discovery §4.3,
+	// RFC 8414 §3.3), or it could redirect us to someone else's keys.
+	if strings.TrimRight(meta.Issuer, "/") != d.issuer {
+		return nil, fmt.Errorf("%s: issuer %q does not match %q", u, meta.Issuer, d.issuer)
+	}
+	if meta.JWKSURI == "" {
+		return nil, fmt.Errorf("%s: no jwks_uri", u)
+	}
+	return &meta, nil
+}
+
+// discoveryURLs returns the metadata locations for issuer. OIDC appends the
+// well-known path to the issuer; RFC 8414 inserts it before the issuer's path.
+func discoveryURLs(issuer string) []string {
+	urls := []string{issuer + OIDCDiscoveryPath}
+	if u, err := url.Parse(issuer); err == nil && u.Host != "" {
+		u.Path = OAuthServerMetadataPath + strings.TrimRight(u.Path, "/")
+		u.RawPath = ""
+		urls = append(urls, u.String())
+	}
+	return urls
+}
@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+// DPoP defaults.
+const (
+	dpopMaxAge     = time.Minute
+	dpopFutureSkew = 30 * time.Second // how far a proof's iat may be ahead of our clock
+	dpopReplayMax  = 100000           // replay-cache entries before new proofs are refused
+)
+
+// DPoP configures RFC 9449 proof-of-possession checks.
+type DPoP struct {
+	// MaxAge is how old a proof's iat may be (default 1 minute). Proof jtis
+	// are remembered for as long, so a proof is accepted once.
+	MaxAge time.Duration
+	// Algorithms restricts the proof `alg` values (default: every supported
+	// algorithm); advertised in the challenge's algs parameter.
+	Algorithms []string
+	// BaseURL, if set, is prepended to the request path to form the expected
+	// htu, e.g. the public URL of a service behind a path-rewriting gateway.
+	// By default it is the request's scheme and host.
+	BaseURL string
+}
+
+// WithDPoP requires DPoP-bound access tokens (RFC 9449): requests must use the
+// `DPoP` authorization scheme and carry one `DPoP` proof header, signed by the
+// JWK it embeds, whose htm/htu match the request, whose iat is fresh, whose
+// jti has not been seen and whose ath is the hash of the access token. The
+// token's `cnf.jkt` must be that JWK's thumbprint. Challenges use the DPoP
+// scheme; Bearer tokens are rejected as missing.
+func WithDPoP(cfg DPoP) AuthOption {
+	return func(a *authConfig) { a.dpop = &cfg }
+}
+
+// dpopVerifier checks DPoP proofs and remembers their jtis.
+type dpopVerifier struct {
+	maxAge     time.Duration
+	algorithms []string
+	baseURL    string
+
+	mu   sync.Mutex
+	seen map[string]time.Time // jkt + jti -> when the entry may be dropped
+}
+
+func newDPoPVerifier(cfg DPoP) (*dpopVerifier, error) {
+	if cfg.MaxAge < 0 {
+		return nil, errors.New("dpop max age must not be negative")
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = dpopMaxAge
+	}
+	if cfg.Algorithms == nil {
+		cfg.Algorithms = supportedAlgorithms
+	}
+	if err := validateAlgorithms(cfg.Algorithms); err != nil {
+		return nil, fmt.Errorf("dpop: %w", err)
+	}
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("dpop base url %q is not absolute", cfg.BaseURL)
+		}
+	}
+	return &dpopVerifier{
+		maxAge:     cfg.MaxAge,
+		algorithms: cfg.Algorithms,
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		seen:       map[string]time.Time{},
+	}, nil
+}
+
+// dpopClaims are the claims of a DPoP proof; verify checks them, so Valid
+// leaves them alone.
+type dpopClaims struct {
+	Jti string  `json:"jti"`
+	Htm string  `json:"htm"`
+	Htu string  `json:"htu"`
+	Iat float64 `json:"iat"`
+	Ath string  `json:"ath"`
+}
+
+func (dpopClaims) Valid() error { return nil }
+
+// verify checks the request's DPoP proof for the access token bound to jkt.
+// Proof problems yield ReasonInvalidDPoPProof or ReasonDPoPReplayed, a key
+// that is not the token's ReasonDPoPKeyMismatch.
+func (d *dpopVerifier) verify(c echo.Context, token, jkt string) error {
+	proofs := c.Request().Header.Values("DPoP")
+	if len(proofs) != 1 {
+		return authFailure(ReasonInvalidDPoPProof, fmt.Errorf("dpop: want one proof header, got %d", len(proofs)))
+	}
+
+	var key jwk
+	claims := &dpopClaims{}
+	parser := &jwt.Parser{ValidMethods: d.algorithms}
+	if _, err := parser.ParseWithClaims(proofs[0], claims, func(t *jwt.Token) (any, error) {
+		if typ, _ := t.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, fmt.Errorf("typ %q is not dpop+jwt", typ)
+		}
+		var err error
+		if key, err = proofJWK(t.Header["jwk"]); err != nil {
+			return nil, err
+		}
+		pub, err := jwkToPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		alg, _ := t.Header["alg"].(string)
+		return pub, keyMatchesAlg(pub, alg)
+	}); err != nil {
+		return authFailure(ReasonInvalidDPoPProof, fmt.Errorf("dpop: %w", err))
+	}
+
+	if claims.Jti == "" {
+		return authFailure(ReasonInvalidDPoPProof, errors.New("dpop: proof has no jti"))
+	}
+	if claims.Htm != c.Request().Method {
+		return authFailure(ReasonInvalidDPoPProof, fmt.Errorf("dpop: htm %q does not match %s", claims.Htm, c.Request().Method))
+	}
+	if want := d.requestURI(c); normalizeHTU(claims.Htu) != want {
+		return authFailure(ReasonInvalidDPoPProof, fmt.Errorf("dpop: htu %q does not match %s", claims.Htu, want))
+	}
+	now := time.Now()
+	iat := time.Unix(int64(claims.Iat), 0)
+	if claims.Iat == 0 || iat.Before(now.Add(-d.maxAge)) || iat.After(now.Add(dpopFutureSkew)) {
+		return authFailure(ReasonInvalidDPoPProof, fmt.Errorf("dpop: iat %s is outside the %s window", iat.UTC().Format(time.RFC3339), d.maxAge))
+	}
+	sum := sha256.Sum256([]byte(token))
+	if claims.Ath != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		return authFailure(ReasonInvalidDPoPProof, errors.New("dpop: ath does not match the access token"))
+	}
+
+	thumbprint, err := jwkThumbprint(key)
+	if err != nil {
+		return authFailure(ReasonInvalidDPoPProof, fmt.Errorf("dpop: %w", err))
+	}
+	if jkt == "" || jkt != thumbprint {
+		return authFailure(ReasonDPoPKeyMismatch, errors.New("dpop: token cnf.jkt does not match the proof key"))
+	}
+
+	// Checked last, so a proof is only used up once it is otherwise valid.
+	if err := d.remember(thumbprint+":"+claims.Jti, iat.Add(d.maxAge), now); err != nil {
+		return err
+	}
+	return nil
+}
+
+// remember records a proof id until expires, failing if it was already seen.
+func (d *dpopVerifier) remember(id string, expires, now time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if until, ok := d.seen[id]; ok && now.Before(until) {
+		return authFailure(ReasonDPoPReplayed, errors.New("dpop: proof jti already used"))
+	}
+	if len(d.seen) >= dpopReplayMax {
+		for k, until := range d.seen {
+			if !now.Before(until) {
+				delete(d.seen, k)
+			}
+		}
+		if len(d.seen) >= dpopReplayMax {
+			return authFailure(ReasonInvalidDPoPProof, errors.New("dpop: replay cache is full"))
+		}
+	}
+	d.seen[id] = expires
+	return nil
+}
+
+// requestURI is the normalized htu the request should carry.
+func (d *dpopVerifier) requestURI(c echo.Context) string {
+	base := d.baseURL
+	if base == "" {
+		base = c.Scheme() + "://" + c.Request().Host
+	}
+	return normalizeHTU(base + c.Request().URL.Path)
+}
+
+// normalizeHTU drops the query and fragment and applies RFC 3986 syntax-based
+// normalization (lowercase scheme and host, no default port, "/" for an empty
+// path), as RFC 9449 §4.3 asks. Unparseable values are returned as-is.
+func normalizeHTU(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	if port := u.Port(); (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path
+}
+
+// proofJWK decodes the proof's `jwk` header, which must be a public key.
+func proofJWK(v any) (jwk, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return jwk{}, errors.New("proof has no jwk header")
+	}
+	if _, private := m["d"]; private {
+		return jwk{}, errors.New("proof jwk contains a private key")
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return jwk{}, err
+	}
+	var k jwk
+	if err := json.Unmarshal(b, &k); err != nil {
+		return jwk{}, fmt.Errorf("proof jwk: %w", err)
+	}
+	return k, nil
+}
+
+// jwkThumbprint is the RFC 7638 SHA-256 thumbprint of k: the hash of its
+// required members, sorted and without whitespace (json.Marshal sorts map
+// keys).
+func jwkThumbprint(k jwk) (string, error) {
+	var members map[string]string
+	switch k.Kty {
+	case "RSA":
+		members = map[string]string{"kty": k.Kty, "n": k.N, "e": k.E}
+	case "EC":
+		members = map[string]string{"kty": k.Kty, "crv": k.Crv, "x": k.X, "y": k.Y}
+	case "OKP":
+		members = map[string]string{"kty": k.Kty, "crv": k.Crv, "x": k.X}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,226 @@
+package middleware_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+)
+
+// proofOpts controls minting of a DPoP proof; zero values give a valid proof
+// for GET http://example.com/protected/.
+type proofOpts struct {
+	htm    string
+	htu    string
+	iat    time.Time
+	jti    string
+	token  string            // access token hashed into ath
+	typ    string            // default dpop+jwt
+	jwkKey *ecdsa.PrivateKey // key embedded in the header, if not the signer
+}
+
+func mintProof(t *testing.T, key *ecdsa.PrivateKey, o proofOpts) string {
+	t.Helper()
+	if o.htm == "" {
+		o.htm = http.MethodGet
+	}
+	if o.htu == "" {
+		o.htu = "http://example.com/protected/"
+	}
+	if o.iat.IsZero() {
+		o.iat = time.Now()
+	}
+	if o.jti == "" {
+		o.jti = uuid.NewString()
+	}
+	if o.typ == "" {
+		o.typ = "dpop+jwt"
+	}
+	if o.jwkKey == nil {
+		o.jwkKey = key
+	}
+	ath := sha256.Sum256([]byte(o.token))
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"jti": o.jti,
+		"htm": o.htm,
+		"htu": o.htu,
+		"iat": o.iat.Unix(),
+		"ath": base64.RawURLEncoding.EncodeToString(ath[:]),
+	})
+	tok.Header["typ"] = o.typ
+	jwk := publicJWK(t, "", "", &o.jwkKey.PublicKey)
+	delete(jwk, "kid")
+	delete(jwk, "use")
+	tok.Header["jwk"] = jwk
+	signed, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// thumbprint is the RFC 7638 thumbprint of an EC public key.
+func thumbprint(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	jwk := publicJWK(t, "", "", &key.PublicKey)
+	sum := sha256.Sum256([]byte(`{"crv":"` + jwk["crv"] + `","kty":"EC","x":"` + jwk["x"] + `","y":"` + jwk["y"] + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func doDPoP(t *testing.T, e *echo.Echo, path, token string, proofs ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "DPoP "+token)
+	for _, p := range proofs {
+		req.Header.Add("DPoP", p)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthN_DPoP(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	holder := mustECKey(t, elliptic.P256())
+	other := mustECKey(t, elliptic.P256())
+	bound := func(key *ecdsa.PrivateKey) string {
+		return mintToken(t, priv, tokenOpts{cls: "user", extra: map[string]any{"cnf": map[string]string{"jkt": thumbprint(t, key)}}})
+	}
+	tok := bound(holder)
+
+	e, _ := newAuthAppWithProducer(t, pubPEM, middleware.WithDPoP(middleware.DPoP{}))
+	proof := mintProof(t, holder, proofOpts{token: tok})
+	require.Equal(t, http.StatusOK, doDPoP(t, e, "/protected/", tok, proof).Code)
+	assert.Equal(t, http.StatusOK, doDPoP(t, e, "/protected/?page=2", tok,
+		mintProof(t, holder, proofOpts{token: tok, htu: "HTTP://Example.com:80/protected/?page=1"})).Code,
+		"htu ignores query and normalizes scheme, host and port")
+
+	rec := doDPoP(t, e, "/protected/", tok, proof)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `DPoP algs="RS256 RS384 RS512 ES256 ES384 EdDSA", error="invalid_dpop_proof", error_description="The DPoP proof has already been used"`,
+		rec.Header().Get("WWW-Authenticate"))
+
+	rec = doGet(t, e, "/protected/", tok)
+	require.Equal(t, http.StatusUnauthorized, rec.Code, "bearer scheme")
+	assert.Equal(t, `DPoP algs="RS256 RS384 RS512 ES256 ES384 EdDSA"`, rec.Header().Get("WWW-Authenticate"))
+
+	cases := []struct {
+		name   string
+		token  string
+		proofs []string
+		reason middleware.AuthFailureReason
+	}{
+		{"no proof", tok, nil, middleware.ReasonInvalidDPoPProof},
+		{"two proofs", tok, []string{mintProof(t, holder, proofOpts{token: tok}), mintProof(t, holder, proofOpts{token: tok})}, middleware.ReasonInvalidDPoPProof},
+		{"not a jwt", tok, []string{"garbage"}, middleware.ReasonInvalidDPoPProof},
+		{"wrong typ", tok, []string{mintProof(t, holder, proofOpts{token: tok, typ: "JWT"})}, middleware.ReasonInvalidDPoPProof},
+		{"signed by another key", tok, []string{mintProof(t, other, proofOpts{token: tok, jwkKey: holder})}, middleware.ReasonInvalidDPoPProof},
+		{"wrong htm", tok, []string{mintProof(t, holder, proofOpts{token: tok, htm: http.MethodPost})}, middleware.ReasonInvalidDPoPProof},
+		{"wrong htu", tok, []string{mintProof(t, holder, proofOpts{token: tok, htu: "http://example.com/other"})}, middleware.ReasonInvalidDPoPProof},
+		{"stale iat", tok, []string{mintProof(t, holder, proofOpts{token: tok, iat: time.Now().Add(-5 * time.Minute)})}, middleware.ReasonInvalidDPoPProof},
+		{"future iat", tok, []string{mintProof(t, holder, proofOpts{token: tok, iat: time.Now().Add(5 * time.Minute)})}, middleware.ReasonInvalidDPoPProof},
+		{"wrong ath", tok, []string{mintProof(t, holder, proofOpts{token: "another-token"})}, middleware.ReasonInvalidDPoPProof},
+		{"token bound to another key", bound(other), nil, middleware.ReasonDPoPKeyMismatch},
+		{"unbound token", mintToken(t, priv, tokenOpts{cls: "user"}), nil, middleware.ReasonDPoPKeyMismatch},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			proofs := tc.proofs
+			if proofs == nil && tc.reason == middleware.ReasonDPoPKeyMismatch {
+				proofs = []string{mintProof(t, holder, proofOpts{token: tc.token})}
+			}
+			e, mock := newAuthAppWithProducer(t, pubPEM, middleware.WithDPoP(middleware.DPoP{}))
+			rec := doDPoP(t, e, "/protected/", tc.token, proofs...)
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+			challenge := rec.Header().Get("WWW-Authenticate")
+			assert.True(t, strings.HasPrefix(challenge, "DPoP "), challenge)
+			if tc.reason == middleware.ReasonDPoPKeyMismatch {
+				assert.Contains(t, challenge, `error="invalid_token"`)
+			} else {
+				assert.Contains(t, challenge, `error="invalid_dpop_proof"`)
+			}
+
+			require.True(t, mock.WaitForSend(time.Second))
+			payload, err := middleware.DecodeLoginPayload(mock.Value().(sdkmodels.EventJson))
+			require.NoError(t, err)
+			assert.Equal(t, tc.reason, payload.Reason)
+		})
+	}
+}
+
+func TestAuthN_DPoPBaseURL(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	holder := mustECKey(t, elliptic.P256())
+	tok := mintToken(t, priv, tokenOpts{cls: "user", extra: map[string]any{"cnf": map[string]string{"jkt": thumbprint(t, holder)}}})
+	e := newAuthApp(t, pubPEM, testIssuer, middleware.WithDPoP(middleware.DPoP{BaseURL: testResource + "/"}))
+
+	assert.Equal(t, http.StatusOK, doDPoP(t, e, "/protected/", tok,
+		mintProof(t, holder, proofOpts{token: tok, htu: testResource + "/protected/"})).Code)
+	assert.Equal(t, http.StatusUnauthorized, doDPoP(t, e, "/protected/", tok,
+		mintProof(t, holder, proofOpts{token: tok})).Code)
+}
+
+func TestAuthN_DPoPStoresAuthorizationScheme(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	holder := mustECKey(t, elliptic.P256())
+	tok := mintToken(t, priv, tokenOpts{cls: "user", extra: map[string]any{"cnf": map[string]string{"jkt": thumbprint(t, holder)}}})
+	e := newAuthApp(t, pubPEM, testIssuer, middleware.WithDPoP(middleware.DPoP{}))
+
+	rec := doDPoP(t, e, "/me", tok, mintProof(t, holder, proofOpts{token: tok, htu: "http://example.com/me"}))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "DPoP "+tok, got["authorization"], "downstream calls forward the token with its scheme")
+}
+
+func TestAuthN_DPoPConfig(t *testing.T) {
+	_, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	for _, cfg := range []middleware.DPoP{
+		{Algorithms: []string{"HS256"}},
+		{MaxAge: -time.Second},
+		{BaseURL: "/relative"},
+	} {
+		cfgSvc := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", uuid.New(), 512)
+		cfgSvc.SetIssuer(testIssuer)
+		_, err := middleware.AuthenticationMiddleware(cfgSvc, &fakes.MockLogger{}, pubPEM,
+			&adapters.ProducerAdapter{Producer: &fakes.MockProducer{}}, "ds.test.v1", middleware.WithDPoP(cfg))
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
+
+func TestRegisterProtectedResource_DPoP(t *testing.T) {
+	e := echo.New()
+	meta := newPRMMeta()
+	meta.DPoPBoundAccessTokensRequired = true
+	middleware.RegisterProtectedResource(e, "", meta)
+
+	rec := doGet(t, e, middleware.WellKnownProtectedResourcePath, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, true, doc["dpop_bound_access_tokens_required"])
+
+	e.GET("/denied", func(echo.Context) error { return echo.ErrUnauthorized })
+	rec = doGet(t, e, "/denied", "")
+	assert.True(t, strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "DPoP resource_metadata="))
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/utils"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
+)
+
+// Metadata keys set on every event built by an EventBuilder. The pod entries
+// are only present when the Kubernetes downward API exposes POD_NAME,
+// POD_NAMESPACE and NODE_NAME to the container.
+const (
+	MetadataServiceName    = "service_name"
+	MetadataServiceVersion = "service_version"
+	MetadataHost           = "host"
+	MetadataPodName        = "pod_name"
+	MetadataPodNamespace   = "pod_namespace"
+	MetadataNodeName       = "node_name"
+)
+
+// EventBuilder builds sdkmodels.EventJson envelopes with the fields every event
+// of this service must carry: a fresh Id, the request and session ids from the
+// request context, the tenant of the authenticated principal, EventSource and
+// CreatedBy set to the service principal id, service and host Metadata, and an
+// Md5Hash of the payload. The middlewares use it for their own events;
+// services can use it for domain events so both look the same to consumers.
+type EventBuilder struct {
+	source   string
+	metadata map[string]string
+}
+
+// EventOption adjusts an event before its hash is computed.
+type EventOption func(*sdkmodels.EventJson)
+
+// WithEventTenant overrides the tenant taken from the request's principal.
+func WithEventTenant(id uuid.UUID) EventOption {
+	return func(e *sdkmodels.EventJson) { e.TenantId = id }
+}
+
+// WithEventMessage sets the optional human-readable message (nil or empty
+// leaves it unset).
+func WithEventMessage(msg *string) EventOption {
+	return func(e *sdkmodels.EventJson) {
+		if msg != nil && *msg != "" {
+			e.Message = msg
+		}
+	}
+}
+
+// WithEventOwner sets the optional owner id (nil or empty leaves it unset).
+func WithEventOwner(owner *string) EventOption {
+	return func(e *sdkmodels.EventJson) {
+		if owner != nil && *owner != "" {
+			e.OwnerId = owner
+		}
+	}
+}
+
+// WithEventTimestamp overrides the event time (default: now, UTC).
+func WithEventTimestamp(t time.Time) EventOption {
+	return func(e *sdkmodels.EventJson) { e.Timestamp = t.UTC() }
+}
+
+// WithEventMetadata adds a metadata entry, overriding a default one.
+func WithEventMetadata(key, value string) EventOption {
+	return func(e *sdkmodels.EventJson) { e.Metadata[key] = value }
+}
+
+// NewEventBuilder captures the service identity from cfg and the host/pod the
+// process runs on. Build it once and share it.
+func NewEventBuilder(cfg interfaces.Config) *EventBuilder {
+	md := maps.Clone(hostMetadata())
+	md[MetadataServiceName] = cfg.Name()
+	md[MetadataServiceVersion] = cfg.Version()
+	return &EventBuilder{
+		source:   utils.CreateServicePrincipleID(cfg),
+		metadata: md,
+	}
+}
+
+// Build returns an event of eventType carrying payload. Request and session ids
+// come from ctx (set by RequestIDMiddleware) and are generated when absent; the
+// tenant comes from the principal set by the authentication middleware, if any.
+func (b *EventBuilder) Build(ctx context.Context, eventType string, payload any, opts ...EventOption) sdkmodels.EventJson {
+	event := sdkmodels.EventJson{
+		Id:          uuid.New(),
+		RequestId:   requestctx.GetOrNewRequestUUID(ctx),
+		SessionId:   requestctx.GetOrNewSessionUUID(ctx),
+		EventType:   eventType,
+		EventSource: b.source,
+		CreatedBy:   b.source,
+		Timestamp:   time.Now().UTC(),
+		Metadata:    maps.Clone(b.metadata),
+		Payload:     payload,
+	}
+	if p, ok := requestctx.GetPrincipal(ctx); ok {
+		event.TenantId = p.TenantID
+	}
+	for _, o := range opts {
+		o(&event)
+	}
+	event.Md5Hash = payloadHash(event.Payload)
+	return event
+}
+
+// payloadHash is the hex MD5 of the payload's JSON encoding. encoding/json
+// sorts map keys, so equal payloads hash equally.
+func payloadHash(payload any) string {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		b = []byte("null")
+	}
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+var hostMetadata = sync.OnceValue(func() map[string]string {
+	md := map[string]string{}
+	if h, err := os.Hostname(); err == nil {
+		md[MetadataHost] = h
+	}
+	for key, env := range map[string]string{
+		MetadataPodName:      "POD_NAME",
+		MetadataPodNamespace: "POD_NAMESPACE",
+		MetadataNodeName:     "NODE_NAME",
+	} {
+		if v := os.Getenv(env); v != "" {
+			md[key] = v
+		}
+	}
+	return md
+})
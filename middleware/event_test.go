@@ -0,0 +1,116 @@
+package middleware_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
+)
+
+func TestEventBuilder_FillsEnvelope(t *testing.T) {
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v2.3.1", uuid.New(), 512)
+	b := middleware.NewEventBuilder(cfg)
+
+	requestID, sessionID, tenantID := uuid.New(), uuid.New(), uuid.New()
+	ctx := requestctx.SetRequestID(context.Background(), requestID.String())
+	ctx = requestctx.SetSessionID(ctx, sessionID.String())
+	ctx = requestctx.SetPrincipal(ctx, requestctx.Principal{Kind: requestctx.KindUser, ID: "a@b.c", TenantID: tenantID})
+
+	ev := b.Build(ctx, "order.created", map[string]any{"order": 1})
+
+	assert.NotEqual(t, uuid.Nil, ev.Id)
+	assert.NotEqual(t, requestID, ev.Id)
+	assert.Equal(t, requestID, ev.RequestId)
+	assert.Equal(t, sessionID, ev.SessionId)
+	assert.Equal(t, tenantID, ev.TenantId)
+	assert.Equal(t, "order.created", ev.EventType)
+	assert.Equal(t, "dp.core.test-svc.v2", ev.EventSource)
+	assert.Equal(t, ev.EventSource, ev.CreatedBy)
+	assert.WithinDuration(t, time.Now(), ev.Timestamp, time.Minute)
+	assert.Equal(t, "test-svc", ev.Metadata[middleware.MetadataServiceName])
+	assert.Equal(t, "v2.3.1", ev.Metadata[middleware.MetadataServiceVersion])
+	assert.NotEmpty(t, ev.Metadata[middleware.MetadataHost])
+
+	sum := md5.Sum([]byte(`{"order":1}`))
+	assert.Equal(t, hex.EncodeToString(sum[:]), ev.Md5Hash)
+}
+
+func TestEventBuilder_OptionsOverrideDefaults(t *testing.T) {
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v1.0.0", uuid.New(), 512)
+	b := middleware.NewEventBuilder(cfg)
+
+	tenantID := uuid.New()
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg, owner := "hello", "owner-1"
+	ev := b.Build(context.Background(), "x", nil,
+		middleware.WithEventTenant(tenantID),
+		middleware.WithEventTimestamp(at),
+		middleware.WithEventMessage(&msg),
+		middleware.WithEventOwner(&owner),
+		middleware.WithEventMetadata("region", "eu-north-1"),
+	)
+
+	assert.NotEqual(t, uuid.Nil, ev.RequestId, "generated when not on the context")
+	assert.NotEqual(t, uuid.Nil, ev.SessionId, "generated when not on the context")
+	assert.Equal(t, tenantID, ev.TenantId)
+	assert.Equal(t, at, ev.Timestamp)
+	assert.Equal(t, &msg, ev.Message)
+	assert.Equal(t, &owner, ev.OwnerId)
+	assert.Equal(t, "eu-north-1", ev.Metadata["region"])
+
+	// Metadata is per event.
+	other := b.Build(context.Background(), "x", nil)
+	assert.NotContains(t, other.Metadata, "region")
+}
+
+func TestAuthN_LoginFailureEventEnvelope(t *testing.T) {
+	_, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+
+	e := echo.New()
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", uuid.New(), 512)
+	cfg.SetIssuer(testIssuer)
+	logger := &fakes.MockLogger{}
+	mock := &fakes.MockProducer{}
+	producer := &adapters.ProducerAdapter{Producer: mock}
+
+	authMW, err := middleware.AuthenticationMiddleware(cfg, logger, pubPEM, producer, "ds.test.v1")
+	require.NoError(t, err)
+	e.Use(middleware.RequestIDMiddleware(logger))
+	e.Use(authMW)
+	e.GET("/protected/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	requestID, sessionID := uuid.New(), uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/protected/", nil)
+	req.Header.Set("X-Request-ID", requestID.String())
+	req.Header.Set("X-Session-ID", sessionID.String())
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	require.True(t, mock.WaitForSend(time.Second))
+	ev, ok := mock.Value().(sdkmodels.EventJson)
+	require.True(t, ok)
+	assert.Equal(t, "login.failure", ev.EventType)
+	assert.NotEqual(t, requestID, ev.Id)
+	assert.Equal(t, requestID, ev.RequestId)
+	assert.Equal(t, sessionID, ev.SessionId)
+	assert.Equal(t, uuid.Nil, ev.TenantId)
+	assert.NotEmpty(t, ev.CreatedBy)
+	assert.NotEmpty(t, ev.Metadata)
+	assert.Len(t, ev.Md5Hash, 32)
+}
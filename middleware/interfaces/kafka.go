@@ -6,3 +6,29 @@ type Producer interface {
 	Send(ctx context.Context, topic string, value any) error
 	Close() error
 }
+
+// Message is an event together with the transport metadata the dispatch path
+// attaches to it: a partition key and string headers.
+type Message struct {
+	Key     string
+	Headers map[string]string
+	Value   any
+}
+
+// MessageProducer is an optional extension of Producer for transports that can
+// carry a key and headers (e.g. Kafka). The dispatch path uses SendMessage when
+// the producer implements it and falls back to Send otherwise, so existing
+// Producer implementations keep working unchanged.
+type MessageProducer interface {
+	Producer
+	SendMessage(ctx context.Context, topic string, msg Message) error
+}
+
+// Consumer reads messages from a topic, the counterpart of Producer. Receive
+// blocks until a message is available or ctx is done; the message Value is
+// the raw record ([]byte) or an already-decoded value. Implementations commit
+// (or otherwise acknowledge) a message once the next Receive is called.
+type Consumer interface {
+	Receive(ctx context.Context, topic string) (Message, error)
+	Close() error
+}
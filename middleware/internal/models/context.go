@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -11,6 +12,14 @@ import (
 	"github.com/google/uuid"
 )
 
+// Time-claim validation errors, distinguished so that callers can report why
+// a token was rejected.
+var (
+	ErrTokenExpired        = errors.New("token has expired")
+	ErrTokenNotYetValid    = errors.New("token not yet valid")
+	ErrTokenIssuedInFuture = errors.New("token issued in the future")
+)
+
 type Context struct {
 	Iss string    `json:"iss"` // Issuer
 	Sub string    `json:"sub"` // Subject
@@ -24,15 +33,27 @@ type Context struct {
 	Cls string   `json:"cls"` // Classification (user or app)
 	Rsc string   `json:"rsc"` // Resource (tenantId:tenantName)
 	Rol []string `json:"rol"` // Roles (array of strings)
+	// OAuth scopes
+	Scope string   `json:"scope,omitempty"` // space-delimited (RFC 8693 §4.2, RFC 7662)
+	Scp   []string `json:"scp,omitempty"`   // array form (accepts a space-delimited string; see UnmarshalJSON)
+	// Proof-of-possession key binding (RFC 7800)
+	Cnf *Confirmation `json:"cnf,omitempty"`
+}
+
+// Confirmation is the `cnf` claim of a sender-constrained token.
+type Confirmation struct {
+	Jkt string `json:"jkt,omitempty"` // JWK SHA-256 thumbprint of the DPoP key (RFC 9449 §6)
 }
 
 // UnmarshalJSON normalizes the `aud` claim, which per RFC 7519 §4.1.3 may be
 // encoded as either a single string or an array of strings, into Aud []string.
-// All other fields decode normally.
+// `scp` is likewise accepted as an array or a space-delimited string. All
+// other fields decode normally.
 func (c *Context) UnmarshalJSON(data []byte) error {
 	type alias Context // avoid recursing into this method
 	aux := struct {
 		Aud json.RawMessage `json:"aud"`
+		Scp json.RawMessage `json:"scp"`
 		*alias
 	}{alias: (*alias)(c)}
 
@@ -40,6 +61,17 @@ func (c *Context) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	c.Scp = nil
+	if len(aux.Scp) != 0 && string(aux.Scp) != "null" {
+		var scp string
+		if err := json.Unmarshal(aux.Scp, &c.Scp); err != nil {
+			if err := json.Unmarshal(aux.Scp, &scp); err != nil {
+				return fmt.Errorf("scp claim is neither string nor string array: %w", err)
+			}
+			c.Scp = strings.Fields(scp)
+		}
+	}
+
 	c.Aud = nil
 	if len(aux.Aud) == 0 || string(aux.Aud) == "null" {
 		return nil
@@ -69,6 +101,18 @@ func (c Context) GetTenantId() (uuid.UUID, error) {
 	return tenantId, nil
 }
 
+// Scopes returns the granted scopes: those of `scope` followed by those of
+// `scp`, without duplicates.
+func (c Context) Scopes() []string {
+	var out []string
+	for _, s := range append(strings.Fields(c.Scope), c.Scp...) {
+		if s != "" && !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 func (c Context) GetTenantName() string {
 	return strings.Split(c.Rsc, ":")[1]
 }
@@ -96,17 +140,17 @@ func (c Context) Valid() error {
 
 	// Validate expiration (exp), allowing a small leeway for clock skew.
 	if exp != 0 && now > exp+clockSkewLeewaySeconds {
-		return errors.New("token has expired")
+		return ErrTokenExpired
 	}
 
 	// Validate not before (nbf)
 	if nbf != 0 && now < nbf-clockSkewLeewaySeconds {
-		return errors.New("token not yet valid")
+		return ErrTokenNotYetValid
 	}
 
 	// Validate issued at (iat)
 	if iat != 0 && now < iat-clockSkewLeewaySeconds {
-		return errors.New("token issued in the future")
+		return ErrTokenIssuedInFuture
 	}
 
 	// NOTE: issuer (`iss`) is enforced per-environment in the auth middleware
@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/claims"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/internal/models"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
+)
+
+// Introspection defaults.
+const (
+	introspectionTimeout  = 5 * time.Second
+	introspectionMaxBody  = 1 << 20
+	introspectionCacheMax = 10000
+)
+
+// Introspection configures RFC 7662 token introspection for opaque tokens.
+type Introspection struct {
+	Endpoint     string // introspection endpoint URL
+	ClientID     string // client credentials, sent as HTTP Basic auth
+	ClientSecret string
+
+	// Issuer is assumed for responses without `iss`; defaults to
+	// Config.Issuer(). Either way the issuer must be trusted.
+	Issuer string
+	// MaxCacheTTL caps how long an active response is cached; zero caches
+	// until the token's exp.
+	MaxCacheTTL time.Duration
+	// HTTPClient replaces the default client (5 s timeout).
+	HTTPClient *http.Client
+	// MapClaims, if set, adjusts the claims mapped from an active response,
+	// e.g. for an authorization server that names its claims differently. It
+	// sees the whole decoded response; an error rejects the token with
+	// ReasonInvalidClaims.
+	MapClaims func(resp map[string]any, c *claims.Context) error
+}
+
+// WithIntrospection authenticates opaque (non-JWT) bearer tokens by calling
+// an RFC 7662 introspection endpoint; JWTs are still verified locally. An
+// active response is mapped onto the token claims (iss, sub, aud, exp, nbf,
+// iat, jti, cls, rsc, rol, scope) and then goes through the same issuer, cls,
+// audience and revocation checks, events and challenges as a JWT. Without
+// sub, the subject is the standard `username` or else `client_id`; without
+// cls, it is requestctx.KindUser when there is a username and requestctx.KindApp
+// for a client_id only.
+// The tenant comes from rsc alone, so the authorization server must return it
+// (or MapClaims must fill it in). Active responses are cached by token hash
+// until exp.
+func WithIntrospection(cfg Introspection) AuthOption {
+	return func(a *authConfig) { a.introspection = &cfg }
+}
+
+// introspector calls the introspection endpoint and caches active results.
+type introspector struct {
+	cfg    Introspection
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]introspectionEntry // by sha256 of the token
+}
+
+type introspectionEntry struct {
+	claims    models.Context
+	expiresAt time.Time
+}
+
+func newIntrospector(cfg Introspection, issuer string) (*introspector, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("introspection endpoint is empty")
+	}
+	if cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, errors.New("introspection client credentials are required")
+	}
+	if cfg.MaxCacheTTL < 0 {
+		return nil, errors.New("introspection max cache TTL must not be negative")
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = issuer
+	}
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: introspectionTimeout}
+	}
+	return &introspector{cfg: cfg, client: client, cache: map[string]introspectionEntry{}}, nil
+}
+
+// isOpaque reports whether token is not a compact JWS (three dot-separated
+// parts) and so has to be introspected.
+func isOpaque(token string) bool {
+	return strings.Count(token, ".") != 2
+}
+
+// introspect returns the claims of an active token, from the cache when
+// possible. An inactive token yields ReasonInactiveToken, an endpoint failure
+// ReasonIntrospectionFailed.
+func (in *introspector) introspect(ctx context.Context, token string) (*models.Context, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	in.mu.Lock()
+	e, ok := in.cache[key]
+	in.mu.Unlock()
+	if ok && now.Before(e.expiresAt) {
+		claims := e.claims
+		return &claims, nil
+	}
+
+	claims, err := in.call(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Exp != 0 {
+		expiresAt := time.Unix(int64(claims.Exp), 0)
+		if in.cfg.MaxCacheTTL > 0 {
+			if limit := now.Add(in.cfg.MaxCacheTTL); limit.Before(expiresAt) {
+				expiresAt = limit
+			}
+		}
+		in.store(key, introspectionEntry{claims: *claims, expiresAt: expiresAt}, now)
+	}
+	return claims, nil
+}
+
+// store caches e, first dropping expired entries. When the cache is full the
+// entry is simply not cached.
+func (in *introspector) store(key string, e introspectionEntry, now time.Time) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if len(in.cache) >= introspectionCacheMax {
+		for k, old := range in.cache {
+			if !now.Before(old.expiresAt) {
+				delete(in.cache, k)
+			}
+		}
+	}
+	if len(in.cache) < introspectionCacheMax {
+		in.cache[key] = e
+	}
+}
+
+// call POSTs the token to the endpoint and maps an active response.
+func (in *introspector) call(ctx context.Context, token string) (*models.Context, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, in.cfg.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, authFailure(ReasonIntrospectionFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(in.cfg.ClientID), url.QueryEscape(in.cfg.ClientSecret))
+
+	resp, err := in.client.Do(req)
+	if err != nil {
+		return nil, authFailure(ReasonIntrospectionFailed, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, authFailure(ReasonIntrospectionFailed, fmt.Errorf("introspection returned status %d", resp.StatusCode))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, introspectionMaxBody))
+	if err != nil {
+		return nil, authFailure(ReasonIntrospectionFailed, err)
+	}
+	return in.parse(body)
+}
+
+// parse maps an introspection response onto token claims. A jti that is not
+// a UUID is dropped, since Principal.JTI is one.
+func (in *introspector) parse(body []byte) (*models.Context, error) {
+	var meta struct {
+		Active   bool   `json:"active"`
+		Jti      string `json:"jti"`
+		Username string `json:"username"`
+		ClientID string `json:"client_id"`
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, authFailure(ReasonIntrospectionFailed, fmt.Errorf("decode introspection response: %w", err))
+	}
+	if !meta.Active {
+		return nil, authFailure(ReasonInactiveToken, errors.New("introspection: token is not active"))
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, authFailure(ReasonIntrospectionFailed, err)
+	}
+	delete(raw, "jti")
+	stripped, err := json.Marshal(raw)
+	if err != nil {
+		return nil, authFailure(ReasonIntrospectionFailed, err)
+	}
+	claims := &models.Context{}
+	if err := json.Unmarshal(stripped, claims); err != nil {
+		return nil, authFailure(ReasonInvalidClaims, fmt.Errorf("introspection claims: %w", err))
+	}
+	if jti, err := uuid.Parse(meta.Jti); err == nil {
+		claims.Jti = jti
+	}
+	if claims.Iss == "" {
+		claims.Iss = in.cfg.Issuer
+	}
+	if claims.Sub == "" {
+		claims.Sub = meta.Username
+		if claims.Sub == "" {
+			claims.Sub = meta.ClientID
+		}
+	}
+	if claims.Cls == "" {
+		switch {
+		case meta.Username != "":
+			claims.Cls = requestctx.KindUser
+		case meta.ClientID != "":
+			claims.Cls = requestctx.KindApp
+		}
+	}
+
+	if in.cfg.MapClaims != nil {
+		var resp map[string]any
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, authFailure(ReasonIntrospectionFailed, err)
+		}
+		if err := in.cfg.MapClaims(resp, claims); err != nil {
+			return nil, authFailure(ReasonInvalidClaims, fmt.Errorf("introspection claims: %w", err))
+		}
+	}
+	return claims, nil
+}
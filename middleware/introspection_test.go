@@ -0,0 +1,197 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/claims"
+)
+
+// newIntrospectionEndpoint serves RFC 7662 responses from tokens, keyed by
+// token; unknown tokens are inactive. It counts the calls.
+func newIntrospectionEndpoint(t *testing.T, tokens map[string]map[string]any) (string, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if id, secret, ok := r.BasicAuth(); !ok || id != "rs-client" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resp, ok := tokens[r.PostFormValue("token")]
+		if !ok {
+			resp = map[string]any{"active": false}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &calls
+}
+
+func activeToken(claims map[string]any) map[string]any {
+	resp := map[string]any{
+		"active": true,
+		"sub":    "alice@example.com",
+		"cls":    "user",
+		"rsc":    uuid.New().String() + ":test-tenant",
+		"aud":    testResource,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"jti":    uuid.New().String(),
+	}
+	for k, v := range claims {
+		resp[k] = v
+	}
+	return resp
+}
+
+func TestAuthN_Introspection(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	endpoint, calls := newIntrospectionEndpoint(t, map[string]map[string]any{
+		"opaque-ok":        activeToken(nil),
+		"opaque-explicit":  activeToken(map[string]any{"iss": testIssuer, "cls": "app", "sub": "client-1"}),
+		"opaque-untrusted": activeToken(map[string]any{"iss": "https://evil.example.com"}),
+		"opaque-aud":       activeToken(map[string]any{"aud": "https://other.example.com"}),
+		"opaque-expired":   activeToken(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}),
+	})
+	opts := []middleware.AuthOption{
+		middleware.WithAudience(testResource),
+		middleware.WithIntrospection(middleware.Introspection{Endpoint: endpoint, ClientID: "rs-client", ClientSecret: "s3cret"}),
+	}
+	e := newAuthApp(t, pubPEM, testIssuer, opts...)
+
+	rec := doGet(t, e, "/me", "opaque-ok")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "alice@example.com", got["id"])
+	assert.Equal(t, testIssuer, got["issuer"], "iss defaults to the configured issuer")
+
+	require.Equal(t, http.StatusOK, doGet(t, e, "/me", "opaque-ok").Code)
+	assert.EqualValues(t, 1, calls.Load(), "active responses are cached")
+
+	rec = doGet(t, e, "/me", "opaque-explicit")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "app", got["kind"])
+
+	// JWTs are still verified locally.
+	require.Equal(t, http.StatusOK, doGet(t, e, "/protected/", mintToken(t, priv, tokenOpts{cls: "user", aud: []string{testResource}})).Code)
+
+	cases := []struct {
+		token  string
+		reason middleware.AuthFailureReason
+	}{
+		{"opaque-unknown", middleware.ReasonInactiveToken},
+		{"opaque-untrusted", middleware.ReasonUntrustedIssuer},
+		{"opaque-aud", middleware.ReasonInvalidAudience},
+		{"opaque-expired", middleware.ReasonExpired},
+	}
+	for _, tc := range cases {
+		t.Run(tc.token, func(t *testing.T) {
+			e, mock := newAuthAppWithProducer(t, pubPEM, opts...)
+			rec := doGet(t, e, "/protected/", tc.token)
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+			require.True(t, mock.WaitForSend(time.Second))
+			payload, err := middleware.DecodeLoginPayload(mock.Value().(sdkmodels.EventJson))
+			require.NoError(t, err)
+			assert.Equal(t, tc.reason, payload.Reason)
+		})
+	}
+}
+
+func TestAuthN_IntrospectionClaimMapping(t *testing.T) {
+	_, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	tenant := uuid.New()
+	standard := func(claims map[string]any) map[string]any {
+		resp := activeToken(claims)
+		delete(resp, "sub")
+		delete(resp, "cls")
+		return resp
+	}
+	endpoint, _ := newIntrospectionEndpoint(t, map[string]map[string]any{
+		"opaque-user":   standard(map[string]any{"username": "alice@example.com", "client_id": "web-app"}),
+		"opaque-client": standard(map[string]any{"client_id": "batch-job"}),
+		"opaque-mapped": standard(map[string]any{"client_id": "partner", "tenant": tenant.String()}),
+		"opaque-bad":    standard(map[string]any{"client_id": "partner"}),
+	})
+	e := newAuthApp(t, pubPEM, testIssuer, middleware.WithIntrospection(middleware.Introspection{
+		Endpoint: endpoint, ClientID: "rs-client", ClientSecret: "s3cret",
+		MapClaims: func(resp map[string]any, c *claims.Context) error {
+			if resp["client_id"] != "partner" {
+				return nil
+			}
+			id, ok := resp["tenant"].(string)
+			if !ok {
+				return errors.New("no tenant")
+			}
+			c.Rsc = id + ":partner"
+			return nil
+		},
+	}))
+	me := func(token string) map[string]string {
+		rec := doGet(t, e, "/me", token)
+		require.Equal(t, http.StatusOK, rec.Code, token)
+		var got map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		return got
+	}
+
+	got := me("opaque-user")
+	assert.Equal(t, "alice@example.com", got["id"], "sub defaults to username")
+	assert.Equal(t, "user", got["kind"])
+
+	got = me("opaque-client")
+	assert.Equal(t, "batch-job", got["id"], "sub defaults to client_id")
+	assert.Equal(t, "app", got["kind"])
+
+	got = me("opaque-mapped")
+	assert.Equal(t, tenant.String(), got["tenant"], "MapClaims fills in the tenant")
+
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/me", "opaque-bad").Code, "a MapClaims error rejects the token")
+}
+
+func TestAuthN_IntrospectionEndpointFailure(t *testing.T) {
+	_, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	endpoint, _ := newIntrospectionEndpoint(t, nil)
+	e, mock := newAuthAppWithProducer(t, pubPEM, middleware.WithIntrospection(middleware.Introspection{
+		Endpoint: endpoint, ClientID: "rs-client", ClientSecret: "wrong",
+	}))
+
+	rec := doGet(t, e, "/protected/", "opaque-ok")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.True(t, mock.WaitForSend(time.Second))
+	payload, err := middleware.DecodeLoginPayload(mock.Value().(sdkmodels.EventJson))
+	require.NoError(t, err)
+	assert.Equal(t, middleware.ReasonIntrospectionFailed, payload.Reason)
+}
+
+func TestAuthN_IntrospectionConfig(t *testing.T) {
+	_, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", uuid.New(), 512)
+	cfg.SetIssuer(testIssuer)
+
+	_, err = middleware.AuthenticationMiddleware(cfg, &fakes.MockLogger{}, pubPEM, &adapters.ProducerAdapter{Producer: &fakes.MockProducer{}}, "ds.test.v1",
+		middleware.WithIntrospection(middleware.Introspection{ClientID: "rs-client", ClientSecret: "s3cret"}))
+	assert.Error(t, err)
+}
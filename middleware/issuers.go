@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
+)
+
+// TrustedIssuer describes an additional issuer whose tokens
+// AuthenticationMiddleware accepts, each with its own keys, audience rules and
+// allowed principal kinds.
+type TrustedIssuer struct {
+	// Issuer is the exact `iss` value (a trailing "/" is ignored).
+	Issuer string
+	// JWKSURI overrides the JWKS location; default
+	// {Issuer}/oauth/.well-known/jwks.json. Ignored when PublicKeyPEM is set.
+	JWKSURI string
+	// JWKSFailoverURIs are tried in order when the JWKS location fails.
+	JWKSFailoverURIs []string
+	// Discovery locates the JWKS from the issuer's metadata, as WithDiscovery
+	// does for Config.Issuer(). Ignored when JWKSURI or PublicKeyPEM is set.
+	Discovery bool
+	// PublicKeyPEM pins a single static key instead of using a JWKS.
+	PublicKeyPEM string
+	// KeySource, when set, resolves this issuer's keys and overrides
+	// PublicKeyPEM, JWKSURI and Discovery.
+	KeySource KeySource
+	// Audience and SharedAudience follow WithAudience/WithSharedAudience for
+	// tokens from this issuer. Both empty disables the audience check.
+	Audience       string
+	SharedAudience string
+	// Classes lists the accepted `cls` values (default: user and app).
+	Classes []string
+}
+
+// WithTrustedIssuers additionally accepts tokens from issuers, e.g. a new IdP
+// during a migration or a federated partner. The issuer is chosen from the
+// token's unverified `iss` and then verified with that issuer's keys only.
+// Config.Issuer() stays trusted with the middleware-wide options unless an
+// entry names it, in which case the entry's rules replace them.
+func WithTrustedIssuers(issuers ...TrustedIssuer) AuthOption {
+	return func(a *authConfig) { a.trustedIssuers = append(a.trustedIssuers, issuers...) }
+}
+
+// issuerTrust is the resolved verification policy for one issuer.
+type issuerTrust struct {
+	issuer         string
+	audience       string
+	sharedAudience string
+	classes        []string
+	keys           KeySource
+	jwks           *jwksCache // the built-in JWKS behind keys, if any
+}
+
+// verifyingKey returns the issuer's key for a token's kid and alg, checking
+// that the key can verify alg whatever the source.
+func (it *issuerTrust) verifyingKey(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	key, err := it.keys.Key(ctx, kid, alg)
+	if err == nil {
+		err = keyMatchesAlg(key, alg)
+	}
+	switch {
+	case err == nil:
+		return key, nil
+	case errors.Is(err, ErrKeyNotFound):
+		return nil, authFailure(ReasonUnknownKey, err)
+	case errors.Is(err, errKeyAlgMismatch):
+		return nil, authFailure(ReasonUnsupportedAlg, err)
+	}
+	return nil, authFailure(ReasonKeyUnavailable, err)
+}
+
+// checkAudience applies the RFC 8707 set-membership test when enabled.
+func (it *issuerTrust) checkAudience(aud []string) error {
+	if it.audience == "" && it.sharedAudience == "" {
+		return nil
+	}
+	if (it.audience != "" && slices.Contains(aud, it.audience)) ||
+		(it.sharedAudience != "" && slices.Contains(aud, it.sharedAudience)) {
+		return nil
+	}
+	return authFailure(ReasonInvalidAudience, fmt.Errorf("token aud %v missing resource %q / shared %q", aud, it.audience, it.sharedAudience))
+}
+
+// checkClass rejects a `cls` the issuer may not mint.
+func (it *issuerTrust) checkClass(cls string) error {
+	if !requestctx.ValidKind(cls) || !slices.Contains(it.classes, cls) {
+		return authFailure(ReasonInvalidClass, fmt.Errorf("token has invalid cls %q for issuer %q", cls, it.issuer))
+	}
+	return nil
+}
+
+// defaultClasses are the principal kinds accepted when none are configured.
+var defaultClasses = []string{requestctx.KindUser, requestctx.KindApp}
+
+// newIssuerTrust resolves a TrustedIssuer into its verification policy.
+func newIssuerTrust(ti TrustedIssuer) (*issuerTrust, error) {
+	issuer := strings.TrimRight(ti.Issuer, "/")
+	if issuer == "" {
+		return nil, errors.New("trusted issuer is empty")
+	}
+	it := &issuerTrust{
+		issuer:         issuer,
+		audience:       ti.Audience,
+		sharedAudience: ti.SharedAudience,
+		classes:        ti.Classes,
+	}
+	if len(it.classes) == 0 {
+		it.classes = defaultClasses
+	}
+	for _, cls := range it.classes {
+		if !requestctx.ValidKind(cls) {
+			return nil, fmt.Errorf("trusted issuer %q: unknown cls %q", issuer, cls)
+		}
+	}
+
+	switch {
+	case ti.KeySource != nil:
+		it.keys = ti.KeySource
+		if js, ok := ti.KeySource.(*JWKSKeySource); ok {
+			it.jwks = js.cache
+		}
+		return it, nil
+	case ti.PublicKeyPEM != "":
+		src, err := NewStaticKeySource(map[string]string{"": ti.PublicKeyPEM})
+		if err != nil {
+			return nil, fmt.Errorf("trusted issuer %q: %w", issuer, err)
+		}
+		it.keys = src
+		return it, nil
+	case ti.JWKSURI != "":
+		it.jwks = newJWKSCache(ti.JWKSURI)
+	case ti.Discovery:
+		it.jwks = newJWKSCache("")
+		it.jwks.discover = newDiscoveryCache(issuer)
+	default:
+		it.jwks = newJWKSCache(issuer + jwksWellKnownSuffix)
+	}
+	it.jwks.failover = ti.JWKSFailoverURIs
+	it.keys = &JWKSKeySource{cache: it.jwks}
+	return it, nil
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"context"
+	"crypto"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/binary"
@@ -9,6 +11,9 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -22,38 +27,80 @@ const (
 	jwksHTTPTimeout     = 5 * time.Second
 )
 
-// jwk is a single RSA key entry from a JWKS document.
+// jwk is a single key entry from a JWKS document: RSA (n, e), EC (crv, x, y)
+// or OKP (crv, x).
 type jwk struct {
 	Kty string `json:"kty"`
 	Kid string `json:"kid"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
 	Use string `json:"use"`
 	Alg string `json:"alg"`
 }
 
+// verifyKey is a parsed JWKS key. alg is the JWK's declared algorithm, if
+// any; a token must then use exactly that algorithm.
+type verifyKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
 type jwksDocument struct {
 	Keys []jwk `json:"keys"`
 }
 
-// jwksCache resolves RSA verifying keys by `kid` from a remote JWKS, caching the
+// jwksCache resolves verifying keys by `kid` from a remote JWKS, caching the
 // set with a TTL and refreshing on an unknown `kid` (cooldown-gated). On a fetch
 // failure it serves the last good key set rather than failing closed.
 type jwksCache struct {
 	uri      string
+	discover *discoveryCache // when set, the JWKS location comes from issuer metadata
+	failover []string        // tried in order when the primary location fails
 	ttl      time.Duration
 	cooldown time.Duration
 	client   *http.Client
 
+	// maxAgeMin/maxAgeMax clamp the IdP's Cache-Control max-age, which then
+	// replaces ttl. Zero maxAgeMax ignores max-age.
+	maxAgeMin time.Duration
+	maxAgeMax time.Duration
+
+	snapshotPath string // where the last good set is persisted ("" = not)
+
 	// refreshMu serializes network refreshes so only one goroutine fetches at a
 	// time; mu guards the in-memory key set. Cache hits take only mu.
 	refreshMu sync.Mutex
 
 	mu         sync.Mutex
-	keysByKid  map[string]*rsa.PublicKey
+	keysByKid  map[string]verifyKey
+	rawKeys    []jwk // the entries keysByKid came from, for snapshots
 	fetchedAt  time.Time
-	lastFetch  time.Time
+	lastFetch  time.Time // last refresh attempt
 	hasFetched bool
+	fromSnap   bool          // keys came from a snapshot: stale until refreshed
+	lastErr    error         // error of the last refresh attempt, nil on success
+	failures   int           // consecutive failed refreshes
+	maxAge     time.Duration // clamped max-age of the last response; 0 = use ttl
+
+	// Validators of the cached set, sent on the next fetch of the same URI so
+	// an unchanged set costs a 304 instead of a download and re-parse.
+	etag         string
+	lastModified string
+	fetchedURI   string
+}
+
+// jwksResponse is the outcome of one successful JWKS fetch.
+type jwksResponse struct {
+	uri          string
+	keys         map[string]verifyKey // nil when notModified
+	raw          []jwk
+	notModified  bool
+	etag         string
+	lastModified string
+	maxAge       time.Duration
 }
 
 func newJWKSCache(uri string) *jwksCache {
@@ -62,21 +109,37 @@ func newJWKSCache(uri string) *jwksCache {
 		ttl:       jwksDefaultTTL,
 		cooldown:  jwksDefaultCooldown,
 		client:    &http.Client{Timeout: jwksHTTPTimeout},
-		keysByKid: map[string]*rsa.PublicKey{},
+		keysByKid: map[string]verifyKey{},
 	}
 }
 
-// getKey returns the RSA public key for kid, refreshing the JWKS if the kid is
-// unknown or the cache is stale (subject to the cooldown). Returns an error when
-// the kid cannot be resolved — callers MUST treat that as a 401, never a 5xx.
+// getKey returns the public key for kid and checks that the key's type (and
+// declared alg, if any) matches the token's alg. Returns an error when the kid
+// cannot be resolved or does not match — callers MUST treat that as a 401,
+// never a 5xx.
+func (j *jwksCache) getKey(kid, alg string) (crypto.PublicKey, error) {
+	k, err := j.resolve(kid)
+	if err != nil {
+		return nil, err
+	}
+	if j.discover != nil {
+		if algs := j.discover.algorithms(); len(algs) > 0 && !slices.Contains(algs, alg) {
+			return nil, fmt.Errorf("%w: issuer does not advertise %s", errKeyAlgMismatch, alg)
+		}
+	}
+	return k.check(kid, alg)
+}
+
+// resolve returns the key for kid, refreshing the JWKS if the kid is unknown or
+// the cache is stale (subject to the cooldown).
 //
 // Cache hits take only the in-memory lock and never wait on the network. When a
 // refresh is needed, refreshMu serializes it so a burst of misses triggers a
 // single fetch that all waiters share, rather than stampeding the IdP or
 // stalling on a lock held across the HTTP call.
-func (j *jwksCache) getKey(kid string) (*rsa.PublicKey, error) {
+func (j *jwksCache) resolve(kid string) (verifyKey, error) {
 	if kid == "" {
-		return nil, errors.New("token missing kid header")
+		return verifyKey{}, fmt.Errorf("token missing kid header: %w", ErrKeyNotFound)
 	}
 
 	// Fast path: a fresh, known key needs no refresh.
@@ -92,78 +155,221 @@ func (j *jwksCache) getKey(kid string) (*rsa.PublicKey, error) {
 		return key, nil
 	}
 
-	// Cooldown gate: refresh at most once per cooldown, even on failure.
+	_ = j.refreshLocked()
+
+	// Return whatever we now have (possibly stale); unknown kid => error => 401.
+	if key, ok := j.lookup(kid, false); ok {
+		return key, nil
+	}
+	return verifyKey{}, fmt.Errorf("%w %q in JWKS", ErrKeyNotFound, kid)
+}
+
+// errJWKSCooldown reports a refresh skipped because one ran within the cooldown.
+var errJWKSCooldown = errors.New("jwks refresh skipped: cooldown")
+
+// refreshLocked fetches the key set, at most once per cooldown even on
+// failure. On failure the last good keys are kept (serve stale). The caller
+// must hold refreshMu.
+func (j *jwksCache) refreshLocked() error {
 	j.mu.Lock()
 	eligible := j.lastFetch.IsZero() || time.Since(j.lastFetch) >= j.cooldown
 	if eligible {
 		j.lastFetch = time.Now()
 	}
 	j.mu.Unlock()
+	if !eligible {
+		return errJWKSCooldown
+	}
+	return j.refreshNowLocked(context.Background())
+}
 
-	if eligible {
-		// On failure (or empty set) keep the last-good keys: serve stale.
-		if keys, err := j.fetch(); err == nil && len(keys) > 0 {
-			j.mu.Lock()
-			j.keysByKid = keys
-			j.fetchedAt = time.Now()
-			j.hasFetched = true
-			j.mu.Unlock()
-		}
+// refreshNowLocked fetches the key set regardless of the cooldown and records
+// the outcome. The caller must hold refreshMu.
+func (j *jwksCache) refreshNowLocked(ctx context.Context) error {
+	resp, err := j.fetch(ctx)
+	j.mu.Lock()
+	// A 304 confirms the cached set is current: it counts as a refresh.
+	if err == nil && resp.notModified && (!j.hasFetched || j.fetchedURI != resp.uri) {
+		err = errors.New("jwks fetch returned 304 without a cached set")
+	}
+	if err != nil {
+		j.lastErr = err
+		j.failures++
+		j.mu.Unlock()
+		return err
+	}
+	j.lastErr, j.failures = nil, 0
+	if !resp.notModified {
+		j.keysByKid, j.rawKeys = resp.keys, resp.raw
+		j.etag, j.lastModified, j.fetchedURI = resp.etag, resp.lastModified, resp.uri
 	}
+	j.fetchedAt = time.Now()
+	j.hasFetched, j.fromSnap = true, false
+	j.maxAge = resp.maxAge
+	snap := jwksSnapshot{URI: resp.uri, FetchedAt: j.fetchedAt, Keys: j.rawKeys}
+	j.mu.Unlock()
 
-	// Return whatever we now have (possibly stale); unknown kid => error => 401.
-	if key, ok := j.lookup(kid, false); ok {
-		return key, nil
+	if j.snapshotPath != "" {
+		_ = j.saveSnapshot(snap) // best effort; the in-memory set is what matters
+	}
+	return nil
+}
+
+// currentTTL is how long the cached set stays fresh: the clamped max-age of
+// the last response when honoured, otherwise the configured TTL.
+func (j *jwksCache) currentTTL() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.ttlLocked()
+}
+
+func (j *jwksCache) ttlLocked() time.Duration {
+	if j.maxAge > 0 {
+		return j.maxAge
 	}
-	return nil, fmt.Errorf("no key for kid %q in JWKS", kid)
+	return j.ttl
 }
 
 // lookup returns the key for kid. When requireFresh is set, it only returns a
 // hit if the cached set is still within its TTL.
-func (j *jwksCache) lookup(kid string, requireFresh bool) (*rsa.PublicKey, bool) {
+func (j *jwksCache) lookup(kid string, requireFresh bool) (verifyKey, bool) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 	key, ok := j.keysByKid[kid]
 	if !ok {
-		return nil, false
+		return verifyKey{}, false
 	}
-	if requireFresh && (!j.hasFetched || time.Since(j.fetchedAt) >= j.ttl) {
-		return nil, false
+	if requireFresh && (!j.hasFetched || j.fromSnap || time.Since(j.fetchedAt) >= j.ttlLocked()) {
+		return verifyKey{}, false
 	}
 	return key, true
 }
 
-func (j *jwksCache) fetch() (map[string]*rsa.PublicKey, error) {
-	resp, err := j.client.Get(j.uri)
+// fetch downloads the JWKS from the primary location, then from each failover
+// URI in turn until one succeeds.
+func (j *jwksCache) fetch(ctx context.Context) (jwksResponse, error) {
+	var (
+		uris []string
+		errs []error
+	)
+	switch {
+	case j.discover != nil:
+		uri, err := j.discover.jwksURI(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			uris = append(uris, uri)
+		}
+	case j.uri != "":
+		uris = append(uris, j.uri)
+	}
+	uris = append(uris, j.failover...)
+
+	for _, uri := range uris {
+		resp, err := j.fetchFrom(ctx, uri)
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return jwksResponse{}, errors.New("no JWKS location configured")
+	}
+	return jwksResponse{}, errors.Join(errs...)
+}
+
+// fetchFrom downloads the JWKS at uri. When the cached set came from the same
+// URI it is a conditional request, and a 304 reports notModified.
+func (j *jwksCache) fetchFrom(ctx context.Context, uri string) (jwksResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return nil, err
+		return jwksResponse{}, err
+	}
+	j.mu.Lock()
+	if j.hasFetched && j.fetchedURI == uri {
+		if j.etag != "" {
+			req.Header.Set("If-None-Match", j.etag)
+		}
+		if j.lastModified != "" {
+			req.Header.Set("If-Modified-Since", j.lastModified)
+		}
+	}
+	j.mu.Unlock()
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return jwksResponse{}, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("jwks fetch returned status %d", resp.StatusCode)
+	out := jwksResponse{
+		uri:          uri,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		maxAge:       j.clampMaxAge(resp.Header.Get("Cache-Control")),
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		out.notModified = true
+		return out, nil
+	default:
+		return jwksResponse{}, fmt.Errorf("jwks fetch returned status %d", resp.StatusCode)
 	}
 
 	var doc jwksDocument
 	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
-		return nil, err
+		return jwksResponse{}, err
+	}
+	out.keys, err = parseJWKS(doc)
+	if err != nil {
+		return jwksResponse{}, err
+	}
+	out.raw = doc.Keys
+	return out, nil
+}
+
+// clampMaxAge returns the Cache-Control max-age clamped to
+// [maxAgeMin, maxAgeMax], or 0 when max-age is not honoured or absent.
+func (j *jwksCache) clampMaxAge(cacheControl string) time.Duration {
+	if j.maxAgeMax <= 0 {
+		return 0
 	}
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		secs, err := strconv.Atoi(strings.Trim(value, `"`))
+		if err != nil || secs < 0 {
+			return 0
+		}
+		return min(max(time.Duration(secs)*time.Second, j.maxAgeMin), j.maxAgeMax)
+	}
+	return 0
+}
 
-	out := make(map[string]*rsa.PublicKey, len(doc.Keys))
+// parseJWKS keeps the usable signing keys of doc.
+func parseJWKS(doc jwksDocument) (map[string]verifyKey, error) {
+	out := make(map[string]verifyKey, len(doc.Keys))
 	for _, k := range doc.Keys {
-		// Only RSA keys are relevant; the set may also carry the OIDC id_token
-		// key or non-RSA keys — skip anything we can't use.
-		if k.Kty != "RSA" || k.Kid == "" {
+		// The set may also carry encryption keys or key types we don't
+		// support — skip anything we can't use for signatures.
+		if k.Kid == "" || k.Use == "enc" {
 			continue
 		}
-		pub, err := jwkToRSAPublicKey(k)
+		pub, err := jwkToPublicKey(k)
 		if err != nil {
 			continue
 		}
-		out[k.Kid] = pub
+		// A declared alg must fit the key type, or the entry is malformed.
+		if k.Alg != "" && keyMatchesAlg(pub, k.Alg) != nil {
+			continue
+		}
+		out[k.Kid] = verifyKey{key: pub, alg: k.Alg}
 	}
 	if len(out) == 0 {
-		return nil, errors.New("jwks contained no usable RSA keys")
+		return nil, errors.New("jwks contained no usable keys")
 	}
 	return out, nil
 }
@@ -1,13 +1,16 @@
 package middleware
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -83,11 +86,11 @@ func TestJWKSCache_ResolvesByKid(t *testing.T) {
 	ts.setKeys(map[string]*rsa.PublicKey{"k1": &k1.PublicKey})
 
 	cache := newJWKSCache(ts.srv.URL)
-	got, err := cache.getKey("k1")
+	got, err := cache.getKey("k1", "RS256")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if got.N.Cmp(k1.N) != 0 {
+	if got.(*rsa.PublicKey).N.Cmp(k1.N) != 0 {
 		t.Fatal("resolved key does not match k1")
 	}
 }
@@ -99,7 +102,7 @@ func TestJWKSCache_UnknownKidReturnsError(t *testing.T) {
 	ts.setKeys(map[string]*rsa.PublicKey{"k1": &k1.PublicKey})
 
 	cache := newJWKSCache(ts.srv.URL)
-	if _, err := cache.getKey("does-not-exist"); err == nil {
+	if _, err := cache.getKey("does-not-exist", "RS256"); err == nil {
 		t.Fatal("expected error for unknown kid")
 	}
 }
@@ -114,18 +117,18 @@ func TestJWKSCache_PicksUpRotatedKey(t *testing.T) {
 	cache := newJWKSCache(ts.srv.URL)
 	cache.cooldown = 0 // allow immediate refresh on unknown kid
 
-	if _, err := cache.getKey("k1"); err != nil {
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
 		t.Fatalf("k1 should resolve: %v", err)
 	}
 
 	// Rotation: server now publishes both k1 (retiring) and k2 (active).
 	ts.setKeys(map[string]*rsa.PublicKey{"k1": &k1.PublicKey, "k2": &k2.PublicKey})
 
-	got, err := cache.getKey("k2")
+	got, err := cache.getKey("k2", "RS256")
 	if err != nil {
 		t.Fatalf("k2 should resolve after refresh: %v", err)
 	}
-	if got.N.Cmp(k2.N) != 0 {
+	if got.(*rsa.PublicKey).N.Cmp(k2.N) != 0 {
 		t.Fatal("resolved key does not match k2")
 	}
 }
@@ -139,13 +142,13 @@ func TestJWKSCache_CooldownGatesRefresh(t *testing.T) {
 	cache := newJWKSCache(ts.srv.URL)
 	cache.cooldown = time.Hour // effectively block re-fetch
 
-	if _, err := cache.getKey("k1"); err != nil {
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
 		t.Fatalf("k1 should resolve: %v", err)
 	}
 	hitsAfterFirst := ts.hitCount()
 
 	// Unknown kid within the cooldown must NOT trigger another fetch.
-	if _, err := cache.getKey("k2"); err == nil {
+	if _, err := cache.getKey("k2", "RS256"); err == nil {
 		t.Fatal("expected error for unknown kid within cooldown")
 	}
 	if ts.hitCount() != hitsAfterFirst {
@@ -163,17 +166,17 @@ func TestJWKSCache_ServesStaleOnFetchFailure(t *testing.T) {
 	cache.ttl = 0      // force every lookup to consider the cache stale
 	cache.cooldown = 0 // and allow a refresh attempt each time
 
-	if _, err := cache.getKey("k1"); err != nil {
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
 		t.Fatalf("k1 should resolve initially: %v", err)
 	}
 
 	// jwks_uri now fails; the last-good key must still resolve.
 	ts.setFail(http.StatusInternalServerError)
-	got, err := cache.getKey("k1")
+	got, err := cache.getKey("k1", "RS256")
 	if err != nil {
 		t.Fatalf("expected last-good key to be served on fetch failure: %v", err)
 	}
-	if got.N.Cmp(k1.N) != 0 {
+	if got.(*rsa.PublicKey).N.Cmp(k1.N) != 0 {
 		t.Fatal("stale-served key does not match k1")
 	}
 }
@@ -193,7 +196,7 @@ func TestJWKSCache_ConcurrentColdStartSingleFetch(t *testing.T) {
 		wg.Add(1)
 		go func(idx int) {
 			defer wg.Done()
-			_, errs[idx] = cache.getKey("k1")
+			_, errs[idx] = cache.getKey("k1", "RS256")
 		}(i)
 	}
 	wg.Wait()
@@ -213,7 +216,269 @@ func TestJWKSCache_MissingKidErrors(t *testing.T) {
 	ts := newJWKSTestServer()
 	defer ts.srv.Close()
 	cache := newJWKSCache(ts.srv.URL)
-	if _, err := cache.getKey(""); err == nil {
+	if _, err := cache.getKey("", "RS256"); err == nil {
 		t.Fatal("expected error for empty kid")
 	}
 }
+
+func TestJWKToPublicKey_RejectsBadECAndOKP(t *testing.T) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	coord := make([]byte, 32)
+	coord[31] = 1 // (1, 1) is not on P-256
+
+	for name, k := range map[string]jwk{
+		"point off curve": {Kty: "EC", Crv: "P-256", X: b64(coord), Y: b64(coord)},
+		"short coords":    {Kty: "EC", Crv: "P-256", X: b64(coord[:31]), Y: b64(coord[:31])},
+		"P-521":           {Kty: "EC", Crv: "P-521", X: b64(coord), Y: b64(coord)},
+		"X25519":          {Kty: "OKP", Crv: "X25519", X: b64(coord)},
+		"short Ed25519":   {Kty: "OKP", Crv: "Ed25519", X: b64(coord[:16])},
+		"oct":             {Kty: "oct"},
+	} {
+		if _, err := jwkToPublicKey(k); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestDiscoveryURLs(t *testing.T) {
+	got := discoveryURLs("https://idp.example.com/tenant-a")
+	want := []string{
+		"https://idp.example.com/tenant-a/.well-known/openid-configuration",
+		"https://idp.example.com/.well-known/oauth-authorization-server/tenant-a",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// waitUntil polls cond until it holds or the deadline passes.
+func waitUntil(t *testing.T, d time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(d)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJWKSCache_BackgroundRefreshPicksUpRotationAndStops(t *testing.T) {
+	k1, k2 := mustRSAKey(t), mustRSAKey(t)
+	ts := newJWKSTestServer()
+	defer ts.srv.Close()
+	ts.setKeys(map[string]*rsa.PublicKey{"k1": &k1.PublicKey})
+
+	cache := newJWKSCache(ts.srv.URL)
+	cache.ttl = 40 * time.Millisecond
+	cache.cooldown = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cache.refreshLoop(ctx, nil)
+		close(done)
+	}()
+
+	// The first refresh runs immediately, so k1 is fresh without a request.
+	waitUntil(t, 2*time.Second, func() bool { _, ok := cache.lookup("k1", true); return ok })
+
+	// A rotation is picked up proactively; no request triggers it.
+	ts.setKeys(map[string]*rsa.PublicKey{"k2": &k2.PublicKey})
+	waitUntil(t, 2*time.Second, func() bool { _, ok := cache.lookup("k2", true); return ok })
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("refresh loop did not stop on context cancel")
+	}
+	hits := ts.hitCount()
+	time.Sleep(100 * time.Millisecond)
+	if ts.hitCount() != hits {
+		t.Fatal("refresh loop kept fetching after stop")
+	}
+}
+
+func TestJWKSCache_BackgroundRefreshReportsErrorsAndServesStale(t *testing.T) {
+	k1 := mustRSAKey(t)
+	ts := newJWKSTestServer()
+	defer ts.srv.Close()
+	ts.setKeys(map[string]*rsa.PublicKey{"k1": &k1.PublicKey})
+
+	cache := newJWKSCache(ts.srv.URL)
+	cache.ttl = 20 * time.Millisecond
+	cache.cooldown = 5 * time.Millisecond
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
+		t.Fatal(err)
+	}
+	ts.setFail(http.StatusInternalServerError)
+
+	errs := make(chan error, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.refreshLoop(ctx, func(_ context.Context, err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+
+	select {
+	case err := <-errs:
+		if errors.Is(err, errJWKSCooldown) {
+			t.Fatalf("cooldown skips must not be reported: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("refresh error was not reported")
+	}
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
+		t.Fatalf("stale k1 should still resolve: %v", err)
+	}
+}
+
+func TestJWKSCache_NextRefreshIsJitteredBeforeTTL(t *testing.T) {
+	cache := newJWKSCache("")
+	lo, hi := cache.ttl/2, cache.ttl*8/10
+	seen := map[time.Duration]bool{}
+	for range 50 {
+		d := cache.nextRefresh(nil)
+		if d < lo || d > hi {
+			t.Fatalf("delay %v outside [%v, %v]", d, lo, hi)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("expected jittered delays")
+	}
+	if d := cache.nextRefresh(errors.New("down")); d < cache.cooldown {
+		t.Fatalf("retry after failure %v sooner than cooldown %v", d, cache.cooldown)
+	}
+}
+
+func TestJWKSCache_ConditionalFetch(t *testing.T) {
+	k1, k2 := mustRSAKey(t), mustRSAKey(t)
+	var (
+		mu                 sync.Mutex
+		etag               = `"v1"`
+		keys               = map[string]*rsa.PublicKey{"k1": &k1.PublicKey}
+		full, notModified  int
+		sawIfModifiedSince bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		sawIfModifiedSince = sawIfModifiedSince || r.Header.Get("If-Modified-Since") != ""
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		out := jwksDocument{}
+		for kid, pub := range keys {
+			out.Keys = append(out.Keys, rsaToJWK(kid, pub))
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+
+	cache := newJWKSCache(srv.URL)
+	cache.cooldown = 0
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged set: a 304 counts as a refresh and keeps the keys.
+	cache.mu.Lock()
+	cache.fetchedAt = time.Time{}
+	cache.mu.Unlock()
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
+		t.Fatalf("k1 should survive a 304: %v", err)
+	}
+	if _, ok := cache.lookup("k1", true); !ok {
+		t.Fatal("a 304 should make the cached set fresh again")
+	}
+
+	// Changed set: new validators, full download.
+	mu.Lock()
+	etag, keys = `"v2"`, map[string]*rsa.PublicKey{"k2": &k2.PublicKey}
+	mu.Unlock()
+	if _, err := cache.getKey("k2", "RS256"); err != nil {
+		t.Fatalf("k2 should resolve after the set changed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if full != 2 || notModified != 1 || !sawIfModifiedSince {
+		t.Fatalf("full=%d notModified=%d sawIfModifiedSince=%v", full, notModified, sawIfModifiedSince)
+	}
+}
+
+func TestJWKSCache_ClampMaxAge(t *testing.T) {
+	cache := newJWKSCache("")
+	if got := cache.clampMaxAge("max-age=600"); got != 0 {
+		t.Fatalf("max-age must be ignored unless enabled, got %v", got)
+	}
+
+	cache.maxAgeMin, cache.maxAgeMax = time.Minute, 10*time.Minute
+	for header, want := range map[string]time.Duration{
+		"public, max-age=300":  5 * time.Minute,
+		"max-age=5":            time.Minute,
+		"MAX-AGE=86400, other": 10 * time.Minute,
+		"no-cache":             0,
+		"max-age=soon":         0,
+		"":                     0,
+	} {
+		if got := cache.clampMaxAge(header); got != want {
+			t.Errorf("%q: got %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestJWKSCache_StatusTracksFailuresAndStaleness(t *testing.T) {
+	k1 := mustRSAKey(t)
+	ts := newJWKSTestServer()
+	defer ts.srv.Close()
+	ts.setKeys(map[string]*rsa.PublicKey{"k1": &k1.PublicKey})
+
+	cache := newJWKSCache(ts.srv.URL)
+	cache.cooldown = 0
+	if st := cache.status(); !st.Stale || len(st.Kids) != 0 || !st.FetchedAt.IsZero() {
+		t.Fatalf("unexpected initial status %+v", st)
+	}
+
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
+		t.Fatal(err)
+	}
+	st := cache.status()
+	if st.Stale || !slices.Equal(st.Kids, []string{"k1"}) || st.FetchedAt.IsZero() || st.URI != ts.srv.URL {
+		t.Fatalf("unexpected status after fetch %+v", st)
+	}
+
+	ts.setFail(http.StatusBadGateway)
+	for range 2 {
+		_, _ = cache.getKey("unknown", "RS256")
+	}
+	st = cache.status()
+	if st.ConsecutiveFailures != 2 || st.LastError == "" || !st.LastAttempt.After(st.FetchedAt) {
+		t.Fatalf("unexpected status after failures %+v", st)
+	}
+
+	cache.mu.Lock()
+	cache.fetchedAt = time.Now().Add(-cache.ttl)
+	cache.mu.Unlock()
+	if st := cache.status(); !st.Stale || len(st.Kids) != 1 {
+		t.Fatalf("expired set should be stale but still loaded: %+v", st)
+	}
+
+	ts.setFail(0)
+	if _, err := cache.getKey("k1", "RS256"); err != nil {
+		t.Fatal(err)
+	}
+	if st := cache.status(); st.ConsecutiveFailures != 0 || st.LastError != "" || st.Stale {
+		t.Fatalf("success should reset failures: %+v", st)
+	}
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// Recommended JWKS cache bounds (key-rotation contract §3). Values outside
+// them are accepted with a warning, e.g. a short TTL in staging.
+const (
+	jwksRecommendedMinTTL      = 5 * time.Minute
+	jwksRecommendedMaxTTL      = 10 * time.Minute
+	jwksRecommendedMinCooldown = 30 * time.Second
+	jwksRecommendedMaxCooldown = 60 * time.Second
+
+	// jwksMinCooldown is a hard floor: without a cooldown a flood of bogus
+	// kids turns into a flood of JWKS fetches.
+	jwksMinCooldown = time.Second
+)
+
+// JWKSOptions tunes the JWKS caches. Zero fields keep the defaults (5 min
+// TTL, 30 s cooldown, 5 s timeout, a plain http.Client).
+type JWKSOptions struct {
+	TTL      time.Duration // how long a fetched key set is fresh
+	Cooldown time.Duration // minimum interval between fetches
+	Timeout  time.Duration // per-fetch HTTP timeout
+
+	// HTTPClient replaces the default client (proxy, private CA roots, ...).
+	// It is not modified: Timeout, when set, applies to a copy.
+	HTTPClient *http.Client
+	// Transport is used with a default client. Mutually exclusive with
+	// HTTPClient.
+	Transport http.RoundTripper
+
+	// URI overrides the JWKS location of Config.Issuer(), e.g. an internal
+	// mirror; FailoverURIs are tried in order when it fails.
+	URI          string
+	FailoverURIs []string
+}
+
+// WithJWKSOptions tunes the JWKS caches of every JWKS-backed issuer; URI and
+// FailoverURIs apply to Config.Issuer() only (see TrustedIssuer for the
+// others). Implies WithJWKS. AuthenticationMiddleware rejects invalid values
+// and logs a warning for values outside the contract's recommended bounds
+// (TTL 5–10 min, cooldown 30–60 s).
+func WithJWKSOptions(o JWKSOptions) AuthOption {
+	return func(a *authConfig) {
+		a.jwksOptions = o
+		a.useJWKS = true
+	}
+}
+
+// WithJWKSCacheControl lets the IdP's `Cache-Control: max-age` on the JWKS
+// response set the cache TTL, clamped to [minTTL, maxTTL]. Without it (or when
+// a response has no max-age) the default TTL applies. AuthenticationMiddleware
+// fails unless 0 < minTTL <= maxTTL.
+func WithJWKSCacheControl(minTTL, maxTTL time.Duration) AuthOption {
+	return func(a *authConfig) {
+		a.jwksMaxAgeMin, a.jwksMaxAgeMax = minTTL, maxTTL
+		a.jwksCacheControl = true
+	}
+}
+
+// validateJWKS checks the JWKS-related options and warns about values outside
+// the contract's recommendations.
+func (a *authConfig) validateJWKS(logger interfaces.Logger) error {
+	o := a.jwksOptions
+	if o.TTL < 0 || o.Cooldown < 0 || o.Timeout < 0 {
+		return errors.New("jwks TTL, cooldown and timeout must not be negative")
+	}
+	if o.Cooldown > 0 && o.Cooldown < jwksMinCooldown {
+		return fmt.Errorf("jwks cooldown %v is below the %v minimum", o.Cooldown, jwksMinCooldown)
+	}
+	if ttl, cooldown := cmp.Or(o.TTL, jwksDefaultTTL), cmp.Or(o.Cooldown, jwksDefaultCooldown); ttl < cooldown {
+		return fmt.Errorf("jwks TTL %v is shorter than the cooldown %v", ttl, cooldown)
+	}
+	if o.HTTPClient != nil && o.Transport != nil {
+		return errors.New("jwks HTTPClient and Transport are mutually exclusive")
+	}
+	if o.TTL > 0 && (o.TTL < jwksRecommendedMinTTL || o.TTL > jwksRecommendedMaxTTL) {
+		logger.Warning(context.Background(), "jwks TTL %v is outside the recommended %v–%v", o.TTL, jwksRecommendedMinTTL, jwksRecommendedMaxTTL)
+	}
+	if o.Cooldown > 0 && (o.Cooldown < jwksRecommendedMinCooldown || o.Cooldown > jwksRecommendedMaxCooldown) {
+		logger.Warning(context.Background(), "jwks cooldown %v is outside the recommended %v–%v", o.Cooldown, jwksRecommendedMinCooldown, jwksRecommendedMaxCooldown)
+	}
+
+	if a.jwksCacheControl && (a.jwksMaxAgeMin <= 0 || a.jwksMaxAgeMin > a.jwksMaxAgeMax) {
+		return fmt.Errorf("invalid JWKS max-age bounds [%v, %v]", a.jwksMaxAgeMin, a.jwksMaxAgeMax)
+	}
+	if a.snapshotDir != "" {
+		if a.snapshotMaxAge <= 0 {
+			return errors.New("jwks snapshot max age must be positive")
+		}
+		if err := os.MkdirAll(a.snapshotDir, 0o700); err != nil {
+			return fmt.Errorf("jwks snapshot dir: %w", err)
+		}
+	}
+	return nil
+}
+
+// httpClient returns the client the JWKS and discovery fetches use, or nil to
+// keep the default.
+func (o JWKSOptions) httpClient() *http.Client {
+	switch {
+	case o.HTTPClient != nil:
+		c := *o.HTTPClient
+		if o.Timeout > 0 {
+			c.Timeout = o.Timeout
+		} else if c.Timeout == 0 {
+			c.Timeout = jwksHTTPTimeout
+		}
+		return &c
+	case o.Transport != nil || o.Timeout > 0:
+		return &http.Client{Transport: o.Transport, Timeout: cmp.Or(o.Timeout, jwksHTTPTimeout)}
+	}
+	return nil
+}
+
+// configureJWKS applies the JWKS-related options to one issuer's cache and
+// seeds it from its snapshot, if enabled. An unusable snapshot is logged and
+// skipped: the cache then starts empty, as without snapshots.
+func (a *authConfig) configureJWKS(j *jwksCache, logger interfaces.Logger) {
+	o := a.jwksOptions
+	j.ttl = cmp.Or(o.TTL, j.ttl)
+	j.cooldown = cmp.Or(o.Cooldown, j.cooldown)
+	if c := o.httpClient(); c != nil {
+		j.client = c
+		if j.discover != nil {
+			j.discover.client = c
+		}
+	}
+	if a.jwksCacheControl {
+		j.maxAgeMin, j.maxAgeMax = a.jwksMaxAgeMin, a.jwksMaxAgeMax
+	}
+	if a.snapshotDir != "" {
+		j.snapshotPath = snapshotFile(a.snapshotDir, j.location())
+		if err := j.loadSnapshot(a.snapshotMaxAge); err != nil {
+			logger.Warning(context.Background(), "ignoring %v", err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package middleware_test
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+// countingTransport counts round trips before delegating to the default.
+type countingTransport struct{ n atomic.Int32 }
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.n.Add(1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestWithJWKSOptions_Validation(t *testing.T) {
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", [16]byte{}, 512)
+	cfg.SetIssuer(testIssuer)
+	build := func(logger *fakes.MockLogger, o middleware.JWKSOptions) error {
+		_, err := middleware.AuthenticationMiddleware(cfg, logger, "", nil, "t", middleware.WithJWKSOptions(o))
+		return err
+	}
+
+	for name, o := range map[string]middleware.JWKSOptions{
+		"negative TTL":          {TTL: -time.Minute},
+		"cooldown below floor":  {Cooldown: 100 * time.Millisecond},
+		"TTL below cooldown":    {TTL: 20 * time.Second, Cooldown: 40 * time.Second},
+		"client plus transport": {HTTPClient: &http.Client{}, Transport: http.DefaultTransport},
+	} {
+		assert.Error(t, build(&fakes.MockLogger{}, o), name)
+	}
+
+	logger := &fakes.MockLogger{}
+	require.NoError(t, build(logger, middleware.JWKSOptions{TTL: 7 * time.Minute, Cooldown: 45 * time.Second}))
+	assert.False(t, logger.WarningCalled())
+
+	// A short staging TTL is allowed, with a warning.
+	logger = &fakes.MockLogger{}
+	require.NoError(t, build(logger, middleware.JWKSOptions{TTL: time.Minute, Cooldown: 10 * time.Second}))
+	assert.True(t, logger.WarningCalled())
+}
+
+func TestWithJWKSOptions_URIFailoverAndTransport(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	mirror := newJWKSIssuer(t, publicJWK(t, "k", "ES256", &key.PublicKey)) + "/oauth/.well-known/jwks.json"
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	transport := &countingTransport{}
+	e := newAuthApp(t, "", testIssuer, middleware.WithJWKSOptions(middleware.JWKSOptions{
+		URI:          dead.URL + "/jwks.json",
+		FailoverURIs: []string{mirror},
+		Transport:    transport,
+	}))
+
+	tok := mintTokenWith(t, jwt.SigningMethodES256, key, tokenOpts{cls: "user", kid: "k"})
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+	assert.Equal(t, int32(2), transport.n.Load(), "primary tried, then the failover")
+}
+
+func TestWithJWKSOptions_CustomClientForPrivateCA(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{publicJWK(t, "k", "ES256", &key.PublicKey)}})
+	}))
+	defer srv.Close()
+	tok := mintTokenWith(t, jwt.SigningMethodES256, key, tokenOpts{cls: "user", kid: "k"})
+
+	// The default client does not trust the test CA.
+	e := newAuthApp(t, "", testIssuer, middleware.WithJWKSOptions(middleware.JWKSOptions{URI: srv.URL}))
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+
+	e = newAuthApp(t, "", testIssuer, middleware.WithJWKSOptions(middleware.JWKSOptions{URI: srv.URL, HTTPClient: srv.Client()}))
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// Startup prefetch defaults and retry backoff.
+const (
+	jwksPrefetchDefaultTimeout  = 10 * time.Second
+	jwksPrefetchDefaultAttempts = 3
+	jwksPrefetchInitialBackoff  = 500 * time.Millisecond
+	jwksPrefetchMaxBackoff      = 5 * time.Second
+)
+
+// JWKSPrefetch configures the startup fetch of WithJWKSPrefetch. Zero fields
+// keep the defaults (10 s overall timeout, 3 attempts).
+type JWKSPrefetch struct {
+	Timeout  time.Duration // overall budget per JWKS, retries included
+	Attempts int           // fetch attempts per JWKS, with jittered backoff
+
+	// Background runs the prefetch without blocking construction: failures
+	// are logged, and a JWKSMonitor's Ready and Handler report not ready
+	// until keys are loaded. Without it AuthenticationMiddleware fails when a
+	// JWKS yields no usable keys.
+	Background bool
+}
+
+// WithJWKSPrefetch fetches every JWKS while AuthenticationMiddleware is
+// constructed instead of on the first request, so a misconfigured JWKS
+// endpoint fails the deployment rather than live traffic. Keys loaded from a
+// snapshot (WithJWKSSnapshot) count as usable when the IdP is unreachable.
+// Has no effect on static-PEM issuers.
+func WithJWKSPrefetch(p JWKSPrefetch) AuthOption {
+	return func(a *authConfig) { a.prefetch = &p }
+}
+
+// prefetchJWKS runs the startup prefetch for the given caches. In fail-fast
+// mode it returns an error naming every JWKS left without keys.
+func (a *authConfig) prefetchJWKS(caches []*jwksCache, logger interfaces.Logger) error {
+	p := *a.prefetch
+	if p.Timeout < 0 || p.Attempts < 0 {
+		return errors.New("jwks prefetch timeout and attempts must not be negative")
+	}
+	timeout := cmp.Or(p.Timeout, jwksPrefetchDefaultTimeout)
+	attempts := cmp.Or(p.Attempts, jwksPrefetchDefaultAttempts)
+
+	if p.Background {
+		for _, j := range caches {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				if err := j.prefetch(ctx, attempts); err != nil {
+					logger.Error(ctx, "%v", err)
+				}
+			}()
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	errs := make([]error, len(caches))
+	var wg sync.WaitGroup
+	for i, j := range caches {
+		wg.Go(func() { errs[i] = j.prefetch(ctx, attempts) })
+	}
+	wg.Wait()
+
+	var failed []error
+	for i, err := range errs {
+		switch {
+		case err == nil:
+		case caches[i].hasKeys():
+			logger.Warning(ctx, "%v; using snapshot keys", err)
+		default:
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// prefetch fetches the key set, bypassing the cooldown, until it yields at
+// least one usable key, attempts run out or ctx is done. refreshMu is
+// released between attempts so requests are not held up by the backoff.
+func (j *jwksCache) prefetch(ctx context.Context, attempts int) error {
+	backoff := jwksPrefetchInitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		j.refreshMu.Lock()
+		j.mu.Lock()
+		j.lastFetch = time.Now()
+		j.mu.Unlock()
+		err = j.refreshNowLocked(ctx)
+		j.refreshMu.Unlock()
+		if err == nil && !j.hasKeys() {
+			err = errors.New("no usable keys")
+		}
+		if err == nil || attempt >= attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("jwks prefetch %s: %w", j.location(), errors.Join(err, ctx.Err()))
+		case <-time.After(backoff + jitter(backoff/2)):
+		}
+		backoff = min(2*backoff, jwksPrefetchMaxBackoff)
+	}
+	if err != nil {
+		return fmt.Errorf("jwks prefetch %s: %w", j.location(), err)
+	}
+	return nil
+}
+
+// hasKeys reports whether the cache holds at least one key, fresh or stale.
+func (j *jwksCache) hasKeys() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.keysByKid) > 0
+}
@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+)
+
+// newFlakyJWKSIssuer serves key after the first `failures` requests fail with
+// 503, and returns the base URL and the request counter.
+func newFlakyJWKSIssuer(t *testing.T, failures int32, key map[string]string) (string, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{key}})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL, &hits
+}
+
+func newPrefetchMiddleware(issuer string, opts ...middleware.AuthOption) error {
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", uuid.New(), 512)
+	cfg.SetIssuer(issuer)
+	producer := &adapters.ProducerAdapter{Producer: &fakes.MockProducer{}}
+	_, err := middleware.AuthenticationMiddleware(cfg, &fakes.MockLogger{}, "", producer, "ds.test.v1", opts...)
+	return err
+}
+
+func TestAuthN_JWKSPrefetch_RetriesUntilKeysLoad(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	iss, hits := newFlakyJWKSIssuer(t, 1, publicJWK(t, "k", "ES256", &key.PublicKey))
+	monitor := middleware.NewJWKSMonitor()
+
+	err := newPrefetchMiddleware(iss, middleware.WithJWKS(), middleware.WithJWKSMonitor(monitor),
+		middleware.WithJWKSPrefetch(middleware.JWKSPrefetch{Timeout: 5 * time.Second}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, hits.Load())
+	assert.NoError(t, monitor.Ready())
+}
+
+func TestAuthN_JWKSPrefetch_FailsFast(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	iss, hits := newFlakyJWKSIssuer(t, 100, publicJWK(t, "k", "ES256", &key.PublicKey))
+
+	err := newPrefetchMiddleware(iss, middleware.WithJWKS(),
+		middleware.WithJWKSPrefetch(middleware.JWKSPrefetch{Timeout: 5 * time.Second, Attempts: 2}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "jwks prefetch")
+	assert.EqualValues(t, 2, hits.Load())
+
+	// An empty key set is no better than an unreachable one.
+	err = newPrefetchMiddleware(newJWKSIssuer(t), middleware.WithJWKS(),
+		middleware.WithJWKSPrefetch(middleware.JWKSPrefetch{Attempts: 1}))
+	assert.ErrorContains(t, err, "no usable keys")
+
+	// The overall timeout bounds the retries.
+	start := time.Now()
+	err = newPrefetchMiddleware(iss, middleware.WithJWKS(),
+		middleware.WithJWKSPrefetch(middleware.JWKSPrefetch{Timeout: 200 * time.Millisecond, Attempts: 10}))
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestAuthN_JWKSPrefetch_BackgroundReportsReadiness(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	iss, _ := newFlakyJWKSIssuer(t, 1, publicJWK(t, "k", "ES256", &key.PublicKey))
+	monitor := middleware.NewJWKSMonitor()
+
+	err := newPrefetchMiddleware(iss, middleware.WithJWKS(), middleware.WithJWKSMonitor(monitor),
+		middleware.WithJWKSPrefetch(middleware.JWKSPrefetch{Background: true}))
+	require.NoError(t, err)
+	assert.Error(t, monitor.Ready(), "not ready before the first successful fetch")
+	assert.Eventually(t, func() bool { return monitor.Ready() == nil }, 5*time.Second, 20*time.Millisecond)
+}
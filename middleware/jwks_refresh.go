@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Background refresh runs between these fractions of the TTL, so keys are
+// replaced before they expire and instances spread their fetches out instead
+// of hitting the IdP in lockstep (key-rotation contract §3, jitter).
+const (
+	jwksRefreshMinFraction = 0.5
+	jwksRefreshMaxFraction = 0.8
+)
+
+// WithBackgroundRefresh refreshes every JWKS in the background before its TTL
+// expires, with random jitter, until ctx is done. Requests then rarely wait on
+// the IdP. Failed refreshes are logged and passed to onErr (which may be nil);
+// the last good keys keep being served and retries respect the refresh
+// cooldown. Has no effect on static-PEM issuers.
+func WithBackgroundRefresh(ctx context.Context, onErr func(ctx context.Context, err error)) AuthOption {
+	return func(a *authConfig) {
+		a.refreshCtx = ctx
+		a.onRefreshErr = onErr
+	}
+}
+
+// refreshLoop keeps the key set fresh until ctx is done. The first refresh
+// runs immediately; later ones are scheduled by nextRefresh.
+func (j *jwksCache) refreshLoop(ctx context.Context, onErr func(ctx context.Context, err error)) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		j.refreshMu.Lock()
+		err := j.refreshLocked()
+		j.refreshMu.Unlock()
+
+		if err != nil && !errors.Is(err, errJWKSCooldown) && onErr != nil {
+			onErr(ctx, fmt.Errorf("jwks refresh %s: %w", j.location(), err))
+		}
+		timer.Reset(j.nextRefresh(err))
+	}
+}
+
+// nextRefresh picks the delay before the next background refresh: a jittered
+// fraction of the TTL after a success, or a jittered cooldown after a failure
+// (never sooner than the cooldown allows).
+func (j *jwksCache) nextRefresh(lastErr error) time.Duration {
+	if lastErr != nil {
+		return j.cooldown + jitter(j.cooldown/2)
+	}
+	ttl := j.currentTTL()
+	lo := time.Duration(float64(ttl) * jwksRefreshMinFraction)
+	hi := time.Duration(float64(ttl) * jwksRefreshMaxFraction)
+	return max(lo+jitter(hi-lo), j.cooldown)
+}
+
+// location names the JWKS for error reports and snapshot files.
+func (j *jwksCache) location() string {
+	if j.discover != nil {
+		return "for " + j.discover.issuer
+	}
+	return j.uri
+}
+
+// jitter returns a random duration in [0, d).
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
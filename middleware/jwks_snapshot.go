@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithJWKSSnapshot persists each JWKS's last successful key set under dir and
+// loads it at startup, so a pod that restarts while the IdP is down can still
+// verify tokens signed with existing keys. A snapshot older than maxAge is
+// ignored. Loaded keys count as stale: the first request still tries the IdP
+// and falls back to them. Writes are best effort.
+func WithJWKSSnapshot(dir string, maxAge time.Duration) AuthOption {
+	return func(a *authConfig) {
+		a.snapshotDir = dir
+		a.snapshotMaxAge = maxAge
+	}
+}
+
+// jwksSnapshot is the on-disk form of a key set.
+type jwksSnapshot struct {
+	URI       string    `json:"uri"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Keys      []jwk     `json:"keys"`
+}
+
+// snapshotFile names the snapshot for a JWKS location within dir.
+func snapshotFile(dir, location string) string {
+	sum := sha256.Sum256([]byte(location))
+	return filepath.Join(dir, "jwks-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadSnapshot seeds the cache from its snapshot file, marked stale so the
+// first lookup refreshes it. A missing file is not an error; a snapshot older
+// than maxAge is rejected.
+func (j *jwksCache) loadSnapshot(maxAge time.Duration) error {
+	data, err := os.ReadFile(j.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap jwksSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("jwks snapshot %s: %w", j.snapshotPath, err)
+	}
+	if age := time.Since(snap.FetchedAt); age > maxAge {
+		return fmt.Errorf("jwks snapshot %s is %v old (max %v)", j.snapshotPath, age.Round(time.Second), maxAge)
+	}
+	keys, err := parseJWKS(jwksDocument{Keys: snap.Keys})
+	if err != nil {
+		return fmt.Errorf("jwks snapshot %s: %w", j.snapshotPath, err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.keysByKid, j.rawKeys = keys, snap.Keys
+	j.fetchedAt, j.hasFetched, j.fromSnap = snap.FetchedAt, true, true
+	return nil
+}
+
+// saveSnapshot writes the current key set atomically (temp file + rename).
+func (j *jwksCache) saveSnapshot(snap jwksSnapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.snapshotPath), ".jwks-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), j.snapshotPath)
+}
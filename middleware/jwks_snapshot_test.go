@@ -0,0 +1,77 @@
+package middleware_test
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+func TestAuthN_JWKSSnapshot_WarmStartDuringOutage(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	var down atomic.Bool
+	var fetches atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{publicJWK(t, "k", "ES256", &key.PublicKey)}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	iss := srv.URL
+	dir := t.TempDir()
+	tok := mintTokenWith(t, jwt.SigningMethodES256, key, tokenOpts{iss: iss, cls: "user", kid: "k"})
+
+	// First boot: the IdP is up and the key set is persisted.
+	e := newAuthApp(t, "", iss, middleware.WithJWKS(), middleware.WithJWKSSnapshot(dir, time.Hour))
+	require.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// Restart with the IdP up: the young snapshot is stale anyway, so the
+	// first request refreshes it.
+	fetches.Store(0)
+	e = newAuthApp(t, "", iss, middleware.WithJWKS(), middleware.WithJWKSSnapshot(dir, time.Hour))
+	require.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+	assert.EqualValues(t, 1, fetches.Load(), "loaded keys are refreshed on first use")
+
+	// Restart during an outage: the snapshot still verifies the token.
+	down.Store(true)
+	fetches.Store(0)
+	e = newAuthApp(t, "", iss, middleware.WithJWKS(), middleware.WithJWKSSnapshot(dir, time.Hour))
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+	assert.Positive(t, fetches.Load(), "the IdP is tried before falling back")
+
+	// Without a snapshot the same restart fails closed.
+	e = newAuthApp(t, "", iss, middleware.WithJWKS())
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+
+	// A snapshot older than the max age is ignored.
+	var snap map[string]any
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &snap))
+	snap["fetched_at"] = time.Now().Add(-2 * time.Hour)
+	data, err = json.Marshal(snap)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(files[0], data, 0o600))
+
+	e = newAuthApp(t, "", iss, middleware.WithJWKS(), middleware.WithJWKSSnapshot(dir, time.Hour))
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JWKSStatus is a point-in-time view of one issuer's JWKS cache.
+type JWKSStatus struct {
+	Issuer              string    `json:"issuer"`
+	URI                 string    `json:"uri"`                   // last fetched JWKS location
+	Kids                []string  `json:"kids"`                  // loaded key ids, sorted
+	FetchedAt           time.Time `json:"fetched_at,omitzero"`   // last successful refresh (or snapshot time)
+	LastAttempt         time.Time `json:"last_attempt,omitzero"` // last refresh attempt, successful or not
+	LastError           string    `json:"last_error,omitempty"`  // error of the last attempt
+	ConsecutiveFailures int       `json:"consecutive_failures"`  // failed attempts since the last success
+	Stale               bool      `json:"stale"`                 // no keys, or older than the TTL
+}
+
+// JWKSMonitor exposes the status of the JWKS caches of the
+// AuthenticationMiddleware instances it is passed to via WithJWKSMonitor.
+// It is read-only and safe for concurrent use.
+type JWKSMonitor struct {
+	mu      sync.Mutex
+	entries []monitoredJWKS
+}
+
+type monitoredJWKS struct {
+	issuer string
+	cache  *jwksCache
+}
+
+// NewJWKSMonitor returns an empty monitor.
+func NewJWKSMonitor() *JWKSMonitor {
+	return &JWKSMonitor{}
+}
+
+// WithJWKSMonitor registers every JWKS-backed issuer of the middleware with m.
+// Static-key issuers have no cache and are not reported.
+func WithJWKSMonitor(m *JWKSMonitor) AuthOption {
+	return func(a *authConfig) { a.monitor = m }
+}
+
+func (m *JWKSMonitor) add(issuer string, cache *jwksCache) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, monitoredJWKS{issuer: issuer, cache: cache})
+}
+
+// Status returns a snapshot per monitored cache, ordered by issuer.
+func (m *JWKSMonitor) Status() []JWKSStatus {
+	m.mu.Lock()
+	entries := slices.Clone(m.entries)
+	m.mu.Unlock()
+
+	out := make([]JWKSStatus, 0, len(entries))
+	for _, e := range entries {
+		st := e.cache.status()
+		st.Issuer = e.issuer
+		out = append(out, st)
+	}
+	sort.SliceStable(out, func(i, k int) bool { return out[i].Issuer < out[k].Issuer })
+	return out
+}
+
+// Handler serves Status as JSON, for mounting on an internal route. It
+// responds 503 when any cache has no keys loaded, 200 otherwise (stale keys
+// still verify tokens, so they don't fail the check).
+func (m *JWKSMonitor) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		status := m.Status()
+		code := http.StatusOK
+		for _, st := range status {
+			if len(st.Kids) == 0 {
+				code = http.StatusServiceUnavailable
+			}
+		}
+		return c.JSON(code, status)
+	}
+}
+
+// Ready returns nil once every monitored cache has keys loaded, and an error
+// naming the issuers still without keys otherwise. It suits a readiness probe,
+// e.g. with WithJWKSPrefetch in background mode.
+func (m *JWKSMonitor) Ready() error {
+	var missing []string
+	for _, st := range m.Status() {
+		if len(st.Kids) == 0 {
+			missing = append(missing, st.Issuer)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("jwks not loaded for %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// status reports the cache's current state.
+func (j *jwksCache) status() JWKSStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := JWKSStatus{
+		URI:                 j.fetchedURI,
+		Kids:                make([]string, 0, len(j.keysByKid)),
+		LastAttempt:         j.lastFetch,
+		ConsecutiveFailures: j.failures,
+		Stale:               len(j.keysByKid) == 0 || j.fromSnap || time.Since(j.fetchedAt) >= j.ttlLocked(),
+	}
+	if st.URI == "" {
+		st.URI = j.location()
+	}
+	if j.hasFetched {
+		st.FetchedAt = j.fetchedAt
+	}
+	if j.lastErr != nil {
+		st.LastError = j.lastErr.Error()
+	}
+	for kid := range j.keysByKid {
+		st.Kids = append(st.Kids, kid)
+	}
+	sort.Strings(st.Kids)
+	return st
+}
@@ -0,0 +1,58 @@
+package middleware_test
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+func TestJWKSMonitor_ReportsIssuersAndServesHealth(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	iss := newJWKSIssuer(t, publicJWK(t, "k2", "ES256", &key.PublicKey), publicJWK(t, "k1", "ES256", &key.PublicKey))
+	_, partnerPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+
+	monitor := middleware.NewJWKSMonitor()
+	e := newAuthApp(t, "", iss,
+		middleware.WithJWKS(),
+		middleware.WithJWKSMonitor(monitor),
+		middleware.WithTrustedIssuers(middleware.TrustedIssuer{Issuer: "https://static.example.com", PublicKeyPEM: partnerPEM}),
+	)
+	health := echo.New()
+	health.GET("/internal/jwks", monitor.Handler())
+	get := func() (int, []middleware.JWKSStatus) {
+		rec := httptest.NewRecorder()
+		health.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/internal/jwks", nil))
+		var out []middleware.JWKSStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+		return rec.Code, out
+	}
+
+	// Only the JWKS-backed issuer is reported; nothing is loaded yet.
+	code, status := get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	require.Len(t, status, 1)
+	assert.Equal(t, iss, status[0].Issuer)
+	assert.True(t, status[0].Stale)
+
+	tok := mintTokenWith(t, jwt.SigningMethodES256, key, tokenOpts{iss: iss, cls: "user", kid: "k1"})
+	require.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+
+	code, status = get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"k1", "k2"}, status[0].Kids)
+	assert.Equal(t, iss+"/oauth/.well-known/jwks.json", status[0].URI)
+	assert.False(t, status[0].Stale)
+	assert.False(t, status[0].FetchedAt.IsZero())
+	assert.Zero(t, status[0].ConsecutiveFailures)
+}
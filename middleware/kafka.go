@@ -2,27 +2,27 @@ package middleware
 
 import (
 	"context"
-	"time"
 
 	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
 )
 
-const kafkaSendTimeout = 3 * time.Second
-
-// sendEventAsync fires a Kafka event in a detached goroutine so it never
-// blocks the HTTP response. It detaches from the request's cancellation
-// (via context.WithoutCancel) while retaining request-scoped values, and
-// bounds the send with kafkaSendTimeout so an unavailable broker can't
-// leak goroutines.
+// sendEventAsync hands a Kafka event to the producer's bounded Dispatcher so it
+// never blocks the HTTP response. The Dispatcher detaches from the request's
+// cancellation (retaining request-scoped values) and bounds each send with its
+// SendTimeout; a full queue is resolved by its overflow policy rather than by
+// spawning more goroutines. Events sent after the producer has been shut down
+// are dropped and logged as such.
 func sendEventAsync(reqCtx context.Context, producer *adapters.ProducerAdapter, logger interfaces.Logger, topic string, event sdkmodels.EventJson, eventType string) {
-	base := context.WithoutCancel(reqCtx)
-	go func() {
-		ctx, cancel := context.WithTimeout(base, kafkaSendTimeout)
-		defer cancel()
-		if err := producer.Send(ctx, topic, event); err != nil {
-			logger.Error(ctx, "Failed to send %s event: %v", eventType, err)
-		}
-	}()
+	ok := producer.Enqueue(reqCtx, topic, event, func(ctx context.Context, err error) {
+		logger.Error(ctx, "Failed to send %s event: %v", eventType, err)
+	})
+	switch {
+	case ok:
+	case producer.Closed():
+		logger.Warning(reqCtx, "Dropped %s event: producer is shut down", eventType)
+	default:
+		logger.Warning(reqCtx, "Dropped %s event: dispatch queue full", eventType)
+	}
 }
@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeySource resolves the public key that verifies a token, by the token's
+// `kid` header (may be empty) and `alg`. Implementations must only return a
+// key that can verify alg; AuthenticationMiddleware checks this again. Any
+// error is treated as a 401.
+type KeySource interface {
+	Key(ctx context.Context, kid, alg string) (crypto.PublicKey, error)
+}
+
+// ErrKeyNotFound is returned (wrapped) when a source has no key for a kid.
+var ErrKeyNotFound = errors.New("no verifying key for kid")
+
+// WithKeySource verifies tokens from Config.Issuer() with src instead of the
+// publicKeyPEM argument or WithJWKS/WithDiscovery. Use it to plug in a
+// FileKeySource, a KeySourceChain or a custom source.
+func WithKeySource(src KeySource) AuthOption {
+	return func(a *authConfig) { a.keySource = src }
+}
+
+// keySet is an in-memory set of keys by kid. A key stored under the empty kid
+// matches any kid, which is how PEM keys without a kid behave.
+type keySet map[string]verifyKey
+
+func (s keySet) key(kid, alg string) (crypto.PublicKey, error) {
+	k, ok := s[kid]
+	if !ok {
+		if k, ok = s[""]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrKeyNotFound, kid)
+		}
+	}
+	return k.check(kid, alg)
+}
+
+// check returns the key if it may verify alg: the JWK's declared alg, if any,
+// must match, and the key type must fit.
+func (k verifyKey) check(kid, alg string) (crypto.PublicKey, error) {
+	if k.alg != "" && k.alg != alg {
+		return nil, fmt.Errorf("%w: key %q is for %s, token uses %s", errKeyAlgMismatch, kid, k.alg, alg)
+	}
+	if err := keyMatchesAlg(k.key, alg); err != nil {
+		return nil, fmt.Errorf("key %q: %w", kid, err)
+	}
+	return k.key, nil
+}
+
+// StaticKeySource is a fixed set of PEM-encoded keys.
+type StaticKeySource struct {
+	keys keySet
+}
+
+var _ KeySource = (*StaticKeySource)(nil)
+
+// NewStaticKeySource parses pems, a map from kid to PEM public key. Use the
+// empty kid for a key that should verify tokens regardless of their kid.
+func NewStaticKeySource(pems map[string]string) (*StaticKeySource, error) {
+	if len(pems) == 0 {
+		return nil, errors.New("static key source has no keys")
+	}
+	keys := make(keySet, len(pems))
+	for kid, p := range pems {
+		pub, err := ParsePublicKey(p)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+		keys[kid] = verifyKey{key: pub}
+	}
+	return &StaticKeySource{keys: keys}, nil
+}
+
+func (s *StaticKeySource) Key(_ context.Context, kid, alg string) (crypto.PublicKey, error) {
+	return s.keys.key(kid, alg)
+}
+
+// JWKSKeySource resolves keys from a remote JWKS with the same caching,
+// rotation and serve-stale behaviour as WithJWKS.
+type JWKSKeySource struct {
+	cache *jwksCache
+}
+
+var _ KeySource = (*JWKSKeySource)(nil)
+
+// NewJWKSKeySource returns a source for the JWKS at uri. When passed to
+// WithKeySource (or TrustedIssuer.KeySource) it also follows
+// WithBackgroundRefresh and WithJWKSCacheControl.
+func NewJWKSKeySource(uri string) *JWKSKeySource {
+	return &JWKSKeySource{cache: newJWKSCache(uri)}
+}
+
+// Key resolves kid from the cached set, refreshing it if needed. The refresh is
+// shared by concurrent callers, so it is not cancelled with ctx.
+func (s *JWKSKeySource) Key(_ context.Context, kid, alg string) (crypto.PublicKey, error) {
+	return s.cache.getKey(kid, alg)
+}
+
+// fileKeySourceCheckInterval bounds how often FileKeySource stats its file.
+const fileKeySourceCheckInterval = time.Second
+
+// FileKeySource serves keys from a file and reloads it when it changes, e.g.
+// a mounted Kubernetes secret. The file is either a JWKS document or one or
+// more PEM public keys; a PEM block's "kid" header names its key. If a reload
+// fails, the last good keys stay in use.
+type FileKeySource struct {
+	path string
+
+	mu        sync.Mutex
+	keys      keySet
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+var _ KeySource = (*FileKeySource)(nil)
+
+// NewFileKeySource loads path, failing if it holds no usable key.
+func NewFileKeySource(path string) (*FileKeySource, error) {
+	s := &FileKeySource{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Key returns the key for kid, first reloading the file if it changed (checked
+// at most once a second).
+func (s *FileKeySource) Key(_ context.Context, kid, alg string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	due := time.Since(s.checkedAt) >= fileKeySourceCheckInterval
+	s.mu.Unlock()
+	if due {
+		_ = s.reloadIfChanged()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys.key(kid, alg)
+}
+
+// Reload reads the file now. On error the previous keys are kept.
+func (s *FileKeySource) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	return s.load(info)
+}
+
+func (s *FileKeySource) reloadIfChanged() error {
+	info, err := os.Stat(s.path)
+	s.mu.Lock()
+	s.checkedAt = time.Now()
+	unchanged := err == nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size
+	s.mu.Unlock()
+	if err != nil || unchanged {
+		return err
+	}
+	return s.load(info)
+}
+
+func (s *FileKeySource) load(info os.FileInfo) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeyFile(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.mu.Lock()
+	s.keys, s.modTime, s.size, s.checkedAt = keys, info.ModTime(), info.Size(), time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// parseKeyFile parses a JWKS document or a PEM bundle.
+func parseKeyFile(data []byte) (keySet, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc jwksDocument
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, err
+		}
+		return parseJWKS(doc)
+	}
+
+	keys := keySet{}
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		pub, err := parsePublicKeyBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		keys[block.Headers["kid"]] = verifyKey{key: pub}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM public keys found")
+	}
+	return keys, nil
+}
+
+// KeySourceChain tries each source in order and returns the first key found,
+// e.g. a local file with the remote JWKS as a fallback.
+type KeySourceChain []KeySource
+
+var _ KeySource = KeySourceChain(nil)
+
+func (c KeySourceChain) Key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	errs := make([]error, 0, len(c))
+	for _, src := range c {
+		key, err := src.Key(ctx, kid, alg)
+		if err == nil {
+			return key, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("%w %q: empty key source chain", ErrKeyNotFound, kid)
+	}
+	return nil, errors.Join(errs...)
+}
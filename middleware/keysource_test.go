@@ -0,0 +1,131 @@
+package middleware_test
+
+import (
+	"context"
+	"crypto/elliptic"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+// withKid adds a "kid" PEM header to a single-block PEM key.
+func withKid(t *testing.T, pemKey, kid string) string {
+	t.Helper()
+	block, _ := pem.Decode([]byte(pemKey))
+	require.NotNil(t, block)
+	block.Headers = map[string]string{"kid": kid}
+	return string(pem.EncodeToMemory(block))
+}
+
+func TestStaticKeySource(t *testing.T) {
+	ec := mustECKey(t, elliptic.P256())
+	ecPEM, err := fakes.PublicKeyPEM(&ec.PublicKey)
+	require.NoError(t, err)
+	_, rsaPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+
+	src, err := middleware.NewStaticKeySource(map[string]string{"ec": ecPEM, "": rsaPEM})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = src.Key(ctx, "ec", middleware.AlgES256)
+	assert.NoError(t, err)
+	_, err = src.Key(ctx, "ec", middleware.AlgRS256)
+	assert.Error(t, err, "EC key cannot verify RS256")
+	_, err = src.Key(ctx, "anything", middleware.AlgRS256)
+	assert.NoError(t, err, "kid-less key matches any kid")
+
+	src, err = middleware.NewStaticKeySource(map[string]string{"ec": ecPEM})
+	require.NoError(t, err)
+	_, err = src.Key(ctx, "other", middleware.AlgES256)
+	assert.ErrorIs(t, err, middleware.ErrKeyNotFound)
+
+	_, err = middleware.NewStaticKeySource(map[string]string{"bad": "nope"})
+	assert.Error(t, err)
+}
+
+func TestFileKeySource_ReloadsOnChangeAndKeepsLastGood(t *testing.T) {
+	_, pem1, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	ec := mustECKey(t, elliptic.P384())
+	pem2, err := fakes.PublicKeyPEM(&ec.PublicKey)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.pem")
+	require.NoError(t, os.WriteFile(path, []byte(withKid(t, pem1, "k1")), 0o600))
+	src, err := middleware.NewFileKeySource(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = src.Key(ctx, "k1", middleware.AlgRS256)
+	require.NoError(t, err)
+
+	// Broken content: the reload fails and the last good keys stay.
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+	assert.Error(t, src.Reload())
+	_, err = src.Key(ctx, "k1", middleware.AlgRS256)
+	require.NoError(t, err)
+
+	// A bundle with both keys is picked up without an explicit Reload.
+	bundle := withKid(t, pem1, "k1") + withKid(t, pem2, "k2")
+	require.NoError(t, os.WriteFile(path, []byte(bundle), 0o600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+	assert.Eventually(t, func() bool {
+		_, err := src.Key(ctx, "k2", middleware.AlgES384)
+		return err == nil
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestFileKeySource_JWKS(t *testing.T) {
+	ed := mustEdKey(t)
+	doc, err := json.Marshal(map[string]any{"keys": []map[string]string{publicJWK(t, "ed", "EdDSA", ed.Public())}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, doc, 0o600))
+
+	src, err := middleware.NewFileKeySource(path)
+	require.NoError(t, err)
+	_, err = src.Key(context.Background(), "ed", middleware.AlgEdDSA)
+	assert.NoError(t, err)
+	_, err = src.Key(context.Background(), "other", middleware.AlgEdDSA)
+	assert.ErrorIs(t, err, middleware.ErrKeyNotFound)
+
+	_, err = middleware.NewFileKeySource(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestAuthN_KeySourceChain(t *testing.T) {
+	localPriv, localPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	remoteKey := mustECKey(t, elliptic.P256())
+	jwksURL := newJWKSIssuer(t, publicJWK(t, "remote", "ES256", &remoteKey.PublicKey)) + "/oauth/.well-known/jwks.json"
+
+	local, err := middleware.NewStaticKeySource(map[string]string{"local": localPEM})
+	require.NoError(t, err)
+	chain := middleware.KeySourceChain{local, middleware.NewJWKSKeySource(jwksURL)}
+	e := newAuthApp(t, "", testIssuer, middleware.WithKeySource(chain))
+
+	tok := mintToken(t, localPriv, tokenOpts{cls: "user", kid: "local"})
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+
+	tok = mintTokenWith(t, jwt.SigningMethodES256, remoteKey, tokenOpts{cls: "user", kid: "remote"})
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+
+	tok = mintToken(t, localPriv, tokenOpts{cls: "user", kid: "unknown"})
+	assert.Equal(t, http.StatusUnauthorized, doGet(t, e, "/protected/", tok).Code)
+
+	_, err = middleware.KeySourceChain{}.Key(context.Background(), "k", middleware.AlgRS256)
+	assert.ErrorIs(t, err, middleware.ErrKeyNotFound)
+}
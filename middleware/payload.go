@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-commonmodels/v3/commonmodels/enum/status"
+)
+
+// Event types emitted by the middlewares.
+const (
+	EventTypeLoginSuccess = "login.success"
+	EventTypeLoginFailure = "login.failure"
+	EventTypeAuthzDenied  = "authz.denied"
+	EventTypeAuthzError   = "authz.error"
+	EventTypeAuditLog     = "audit.log"
+	EventTypeUsageReport  = "usage.report"
+	EventTypeTokenRevoked = "token.revoked"
+)
+
+// PayloadSchemaVersion is the schema_version of the payloads this library
+// emits. It is bumped only for incompatible changes; fields may be added
+// within a version. Events from releases that predate it decode as version 1.
+const PayloadSchemaVersion = 1
+
+// ErrPayloadVersion is returned when decoding a payload with a newer
+// schema_version than this library understands.
+var ErrPayloadVersion = errors.New("unsupported payload schema version")
+
+// LoginPayload is the payload of login.success and login.failure events. The
+// token fields are empty for failures.
+type LoginPayload struct {
+	SchemaVersion int       `json:"schema_version"`
+	Subject       string    `json:"subject"`
+	Cls           string    `json:"cls,omitempty"`
+	Jti           string    `json:"jti,omitempty"`
+	TenantID      uuid.UUID `json:"tenant_id,omitzero"`
+	Path          string    `json:"path"`
+	UserAgent     string    `json:"user_agent"`
+	RemoteAddr    string    `json:"remote_addr"`
+
+	// Reason says why a login failed; empty for login.success.
+	Reason AuthFailureReason `json:"reason,omitempty"`
+}
+
+// AuthzPayload is the payload of authz.denied and authz.error events.
+type AuthzPayload struct {
+	SchemaVersion int     `json:"schema_version"`
+	StatusCode    int     `json:"status_code"`
+	Subject       string  `json:"subject"`
+	Error         *string `json:"error"`
+	Path          string  `json:"path"`
+	UserAgent     string  `json:"user_agent"`
+	RemoteAddr    string  `json:"remote_addr"`
+	// RequiredScopes is set when RequireScopes denied the request.
+	RequiredScopes []string `json:"required_scopes,omitempty"`
+}
+
+// AuditPayload is the payload of audit.log events. Payload is the JSON request
+// body of mutating requests; ResponsePayload the JSON response body of error
+// responses. Both are null otherwise.
+type AuditPayload struct {
+	SchemaVersion   int             `json:"schema_version"`
+	Jti             uuid.UUID       `json:"jti"`
+	HTTPMethod      string          `json:"http_method"`
+	Resource        string          `json:"resource"`
+	Endpoint        string          `json:"endpoint"`
+	FullURL         string          `json:"full_url"`
+	SourceIP        string          `json:"source_ip"`
+	UserAgent       string          `json:"user_agent"`
+	Payload         json.RawMessage `json:"payload"`
+	Subject         string          `json:"subject"`
+	StatusCode      int             `json:"status_code"`
+	ResponsePayload json.RawMessage `json:"response_payload"`
+}
+
+// UsagePayload is the payload of usage.report events.
+type UsagePayload struct {
+	SchemaVersion int           `json:"schema_version"`
+	ProductID     uuid.UUID     `json:"product_id"`
+	MemoryMB      int16         `json:"memory_mb"`
+	StartTime     time.Time     `json:"start_time"`
+	EndTime       time.Time     `json:"end_time"`
+	Status        status.Status `json:"status"`
+	UserID        string        `json:"user_id"`
+	ServiceName   string        `json:"service_name"`
+}
+
+// RevocationPayload is the payload of token.revoked events, consumed by
+// ConsumeRevocations.
+type RevocationPayload struct {
+	SchemaVersion int `json:"schema_version"`
+	Revocation
+}
+
+// DecodeLoginPayload decodes the payload of a login.success or login.failure
+// event.
+func DecodeLoginPayload(ev sdkmodels.EventJson) (LoginPayload, error) {
+	p, err := decodePayload[LoginPayload](ev, EventTypeLoginSuccess, EventTypeLoginFailure)
+	if err != nil {
+		return p, err
+	}
+	return p, checkVersion(&p.SchemaVersion)
+}
+
+// DecodeAuthzPayload decodes the payload of an authz.denied or authz.error
+// event.
+func DecodeAuthzPayload(ev sdkmodels.EventJson) (AuthzPayload, error) {
+	p, err := decodePayload[AuthzPayload](ev, EventTypeAuthzDenied, EventTypeAuthzError)
+	if err != nil {
+		return p, err
+	}
+	return p, checkVersion(&p.SchemaVersion)
+}
+
+// DecodeAuditPayload decodes the payload of an audit.log event.
+func DecodeAuditPayload(ev sdkmodels.EventJson) (AuditPayload, error) {
+	p, err := decodePayload[AuditPayload](ev, EventTypeAuditLog)
+	if err != nil {
+		return p, err
+	}
+	return p, checkVersion(&p.SchemaVersion)
+}
+
+// DecodeUsagePayload decodes the payload of a usage.report event.
+func DecodeUsagePayload(ev sdkmodels.EventJson) (UsagePayload, error) {
+	p, err := decodePayload[UsagePayload](ev, EventTypeUsageReport)
+	if err != nil {
+		return p, err
+	}
+	return p, checkVersion(&p.SchemaVersion)
+}
+
+// DecodeRevocationPayload decodes the payload of a token.revoked event.
+func DecodeRevocationPayload(ev sdkmodels.EventJson) (RevocationPayload, error) {
+	p, err := decodePayload[RevocationPayload](ev, EventTypeTokenRevoked)
+	if err != nil {
+		return p, err
+	}
+	return p, checkVersion(&p.SchemaVersion)
+}
+
+// decodePayload converts ev.Payload to T. The payload is either T itself (an
+// event that never left the process) or its JSON form as decoded from Kafka.
+func decodePayload[T any](ev sdkmodels.EventJson, eventTypes ...string) (T, error) {
+	var out T
+	if !slices.Contains(eventTypes, ev.EventType) {
+		return out, fmt.Errorf("unexpected event type %q, want one of %v", ev.EventType, eventTypes)
+	}
+	switch p := ev.Payload.(type) {
+	case T:
+		return p, nil
+	case *T:
+		if p != nil {
+			return *p, nil
+		}
+	}
+	if ev.Payload == nil {
+		return out, fmt.Errorf("%s event has no payload", ev.EventType)
+	}
+	b, err := json.Marshal(ev.Payload)
+	if err != nil {
+		return out, fmt.Errorf("encode %s payload: %w", ev.EventType, err)
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return out, fmt.Errorf("decode %s payload: %w", ev.EventType, err)
+	}
+	return out, nil
+}
+
+// checkVersion rejects newer schema versions and maps a missing version
+// (payloads emitted before versioning) to 1.
+func checkVersion(v *int) error {
+	if *v == 0 {
+		*v = 1
+	}
+	if *v > PayloadSchemaVersion {
+		return fmt.Errorf("%w: %d", ErrPayloadVersion, *v)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+	"github.com/grasp-labs/ds-go-commonmodels/v3/commonmodels/enum/status"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+// viaKafka round-trips an event through JSON, the way a consumer receives it.
+func viaKafka(t *testing.T, ev sdkmodels.EventJson) sdkmodels.EventJson {
+	t.Helper()
+	b, err := json.Marshal(ev)
+	require.NoError(t, err)
+	var out sdkmodels.EventJson
+	require.NoError(t, json.Unmarshal(b, &out))
+	return out
+}
+
+func TestDecodeUsagePayload_RoundTrip(t *testing.T) {
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v1.0.0", uuid.New(), 512)
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	want := middleware.UsagePayload{
+		SchemaVersion: middleware.PayloadSchemaVersion,
+		ProductID:     uuid.New(),
+		MemoryMB:      512,
+		StartTime:     start,
+		EndTime:       start.Add(time.Second),
+		Status:        status.Draft,
+		UserID:        "user@email.com",
+		ServiceName:   "test-svc",
+	}
+	ev := middleware.NewEventBuilder(cfg).Build(context.Background(), middleware.EventTypeUsageReport, want)
+
+	got, err := middleware.DecodeUsagePayload(ev)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	got, err = middleware.DecodeUsagePayload(viaKafka(t, ev))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestDecodeAuditPayload_KeepsRawBodies(t *testing.T) {
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v1.0.0", uuid.New(), 512)
+	want := middleware.AuditPayload{
+		SchemaVersion: middleware.PayloadSchemaVersion,
+		Jti:           uuid.New(),
+		HTTPMethod:    "POST",
+		Payload:       json.RawMessage(`{"a":1}`),
+		StatusCode:    201,
+	}
+	ev := middleware.NewEventBuilder(cfg).Build(context.Background(), middleware.EventTypeAuditLog, want)
+
+	got, err := middleware.DecodeAuditPayload(viaKafka(t, ev))
+	require.NoError(t, err)
+	assert.Equal(t, want.Jti, got.Jti)
+	assert.JSONEq(t, `{"a":1}`, string(got.Payload))
+	assert.Equal(t, "null", string(got.ResponsePayload))
+}
+
+func TestDecodeLoginPayload_LegacyMapPayload(t *testing.T) {
+	// Events emitted before typed payloads carried a map without a version.
+	ev := sdkmodels.EventJson{
+		EventType: middleware.EventTypeLoginFailure,
+		Payload: &map[string]any{
+			"subject":     "",
+			"path":        "/x",
+			"user_agent":  "curl",
+			"remote_addr": "10.0.0.1",
+		},
+	}
+	got, err := middleware.DecodeLoginPayload(ev)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.SchemaVersion)
+	assert.Equal(t, "/x", got.Path)
+	assert.Equal(t, uuid.Nil, got.TenantID)
+}
+
+func TestDecodePayload_Rejects(t *testing.T) {
+	_, err := middleware.DecodeAuthzPayload(sdkmodels.EventJson{EventType: middleware.EventTypeLoginSuccess, Payload: map[string]any{}})
+	assert.ErrorContains(t, err, "unexpected event type")
+
+	_, err = middleware.DecodeAuthzPayload(sdkmodels.EventJson{EventType: middleware.EventTypeAuthzDenied})
+	assert.ErrorContains(t, err, "no payload")
+
+	_, err = middleware.DecodeAuthzPayload(sdkmodels.EventJson{
+		EventType: middleware.EventTypeAuthzError,
+		Payload:   map[string]any{"schema_version": middleware.PayloadSchemaVersion + 1},
+	})
+	assert.ErrorIs(t, err, middleware.ErrPayloadVersion)
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
@@ -18,6 +19,10 @@ type ResourceMetadata struct {
 	Resource             string   // e.g. "https://grasp-daas.com/api/ai-gateway/v1"
 	AuthorizationServers []string // e.g. ["https://auth.grasp-daas.com"]
 	ScopesSupported      []string // advisory; e.g. ["read","write"]
+
+	// DPoPBoundAccessTokensRequired advertises that only DPoP-bound tokens
+	// are accepted (set it together with WithDPoP).
+	DPoPBoundAccessTokensRequired bool
 }
 
 // RegisterProtectedResource wires a service's RFC 9728 discovery surface:
@@ -25,7 +30,10 @@ type ResourceMetadata struct {
 //  1. Serves GET {prefix}{WellKnownProtectedResourcePath} (public, no auth,
 //     CORS "*", Cache-Control "public, max-age=3600") returning the PRM doc.
 //  2. Wraps e.HTTPErrorHandler so every 401 carries
-//     WWW-Authenticate: Bearer resource_metadata="{Resource}{WellKnownProtectedResourcePath}".
+//     WWW-Authenticate: Bearer resource_metadata="{Resource}{WellKnownProtectedResourcePath}"
+//     (DPoP with DPoPBoundAccessTokensRequired).
+//     A challenge set upstream (e.g. AuthenticationMiddleware's
+//     error="invalid_token") is kept and gains the parameter if it lacks it.
 //
 // Call once, on the root echo instance, before routes are served. The metadata
 // route must NOT be behind the auth chain.
@@ -33,12 +41,22 @@ func RegisterProtectedResource(e *echo.Echo, prefix string, meta ResourceMetadat
 	e.GET(prefix+WellKnownProtectedResourcePath, protectedResourceHandler(meta))
 
 	metadataURL := meta.Resource + WellKnownProtectedResourcePath
-	challenge := fmt.Sprintf("Bearer resource_metadata=%q", metadataURL)
+	scheme := "Bearer"
+	if meta.DPoPBoundAccessTokensRequired {
+		scheme = "DPoP"
+	}
+	challenge := fmt.Sprintf("%s resource_metadata=%q", scheme, metadataURL)
 	base := e.HTTPErrorHandler // capture (default or already-wrapped)
 	e.HTTPErrorHandler = func(err error, c echo.Context) {
 		if he, ok := err.(*echo.HTTPError); ok &&
 			he.Code == http.StatusUnauthorized && !c.Response().Committed {
-			c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+			h := c.Response().Header()
+			switch existing := h.Get(echo.HeaderWWWAuthenticate); {
+			case existing == "":
+				h.Set(echo.HeaderWWWAuthenticate, challenge)
+			case !strings.Contains(existing, "resource_metadata="):
+				h.Set(echo.HeaderWWWAuthenticate, addChallengeParam(existing, "resource_metadata", metadataURL))
+			}
 		}
 		base(err, c)
 	}
@@ -48,11 +66,15 @@ func protectedResourceHandler(meta ResourceMetadata) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		c.Response().Header().Set(echo.HeaderAccessControlAllowOrigin, "*")
 		c.Response().Header().Set("Cache-Control", "public, max-age=3600")
-		return c.JSON(http.StatusOK, map[string]any{
+		doc := map[string]any{
 			"resource":                 meta.Resource,
 			"authorization_servers":    meta.AuthorizationServers,
 			"bearer_methods_supported": []string{"header"},
 			"scopes_supported":         meta.ScopesSupported,
-		})
+		}
+		if meta.DPoPBoundAccessTokensRequired {
+			doc["dpop_bound_access_tokens_required"] = true
+		}
+		return c.JSON(http.StatusOK, doc)
 	}
 }
@@ -9,12 +9,14 @@ import (
 )
 
 const (
-	headerRequestID = "X-Request-ID"
-	headerSessionID = "X-Session-ID"
+	headerRequestID   = "X-Request-ID"
+	headerSessionID   = "X-Session-ID"
+	headerTraceparent = "traceparent"
 )
 
 // RequestIDMiddleware sets X-Request-ID if missing, and propagates it.
 // SessionID is propagated if valid; if invalid/missing, we leave it unset (adjust if you want to synthesize one).
+// An incoming W3C traceparent is kept on the context so emitted events can carry it.
 func RequestIDMiddleware(logger interfaces.Logger) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -38,6 +40,9 @@ func RequestIDMiddleware(logger interfaces.Logger) echo.MiddlewareFunc {
 			if sessionID != "" {
 				ctx = requestctx.SetSessionID(ctx, sessionID)
 			}
+			if tp := req.Header.Get(headerTraceparent); tp != "" {
+				ctx = requestctx.SetTraceparent(ctx, tp)
+			}
 			c.SetRequest(req.WithContext(ctx))
 
 			// Expose via response headers
@@ -43,3 +43,21 @@ func TestRequestIDMiddleware_GeneratesID(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, headerID, capturedRequestID)
 }
+
+func TestRequestIDMiddleware_KeepsTraceparent(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.RequestIDMiddleware(&fakes.MockLogger{}))
+
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var captured string
+	e.GET("/", func(c echo.Context) error {
+		captured = requestctx.GetTraceparent(c.Request().Context())
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", tp)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, tp, captured)
+}
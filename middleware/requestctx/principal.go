@@ -23,6 +23,8 @@ type Principal struct {
 	TenantID uuid.UUID // parsed from rsc (substring before the first ':')
 	Roles    []string  // rol: coarse flags, advisory only
 	JTI      uuid.UUID // token id, for audit
+	Issuer   string    // iss: the trusted issuer that authenticated the token
+	Scopes   []string  // scope / scp: granted OAuth scopes
 }
 
 // ValidKind reports whether cls is a recognized principal kind.
@@ -43,6 +45,8 @@ func NewPrincipal(c *claims.Context) (Principal, error) {
 		TenantID: tenantID,
 		Roles:    c.Rol,
 		JTI:      c.Jti,
+		Issuer:   c.Iss,
+		Scopes:   c.Scopes(),
 	}, nil
 }
 
@@ -0,0 +1,16 @@
+package requestctx
+
+import "context"
+
+const traceparentKey ctxKey = "traceparent"
+
+// GetTraceparent returns the W3C traceparent from context.
+func GetTraceparent(ctx context.Context) string {
+	val, _ := ctx.Value(traceparentKey).(string)
+	return val
+}
+
+// SetTraceparent sets the W3C traceparent in the context.
+func SetTraceparent(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceparentKey, traceparent)
+}
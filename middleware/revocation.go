@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenInfo identifies a verified token for a revocation check.
+type TokenInfo struct {
+	JTI      uuid.UUID
+	Subject  string
+	TenantID uuid.UUID
+	Issuer   string
+	IssuedAt time.Time // zero if the token has no iat
+}
+
+// RevocationChecker reports whether a verified token has been revoked. An
+// error rejects the token: revocation checks fail closed.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, tok TokenInfo) (bool, error)
+}
+
+// RevocationStore is a RevocationChecker that revocations can be added to,
+// e.g. by ConsumeRevocations.
+type RevocationStore interface {
+	RevocationChecker
+	Revoke(ctx context.Context, r Revocation) error
+}
+
+// Revocation revokes the tokens matching all of its set fields: one token by
+// JTI, or every token of a Subject and/or TenantID. Only tokens issued before
+// IssuedBefore match; left zero, it is the time the revocation is stored. With
+// no selector set it revokes every token issued before IssuedBefore, which
+// must then be set explicitly (ErrUnscopedRevocation).
+type Revocation struct {
+	JTI          uuid.UUID `json:"jti,omitzero"`
+	Subject      string    `json:"subject,omitempty"`
+	TenantID     uuid.UUID `json:"tenant_id,omitzero"`
+	IssuedBefore time.Time `json:"issued_before,omitzero"`
+	ExpiresAt    time.Time `json:"expires_at,omitzero"` // when the entry may be dropped; set by the store if zero
+}
+
+// ErrUnscopedRevocation rejects a Revocation with no JTI, Subject or TenantID
+// and no IssuedBefore, which would revoke every token issued so far.
+var ErrUnscopedRevocation = errors.New("revocation has no selector and no issued_before")
+
+// validate rejects revocations that would revoke everything by accident.
+func (r Revocation) validate() error {
+	if r.JTI == uuid.Nil && r.Subject == "" && r.TenantID == uuid.Nil && r.IssuedBefore.IsZero() {
+		return ErrUnscopedRevocation
+	}
+	return nil
+}
+
+// matches reports whether tok falls under r. A token without iat is treated
+// as issued before any revocation.
+func (r Revocation) matches(tok TokenInfo) bool {
+	if r.JTI != uuid.Nil && r.JTI != tok.JTI {
+		return false
+	}
+	if r.Subject != "" && r.Subject != tok.Subject {
+		return false
+	}
+	if r.TenantID != uuid.Nil && r.TenantID != tok.TenantID {
+		return false
+	}
+	return tok.IssuedAt.IsZero() || tok.IssuedAt.Before(r.IssuedBefore)
+}
+
+// WithRevocationChecker rejects verified tokens that rc reports as revoked,
+// with ReasonRevoked. A checker error rejects the token too (fail closed).
+func WithRevocationChecker(rc RevocationChecker) AuthOption {
+	return func(a *authConfig) { a.revocation = rc }
+}
+
+// MemoryRevocationStore keeps revocations in memory for ttl, which should be at
+// least the longest token lifetime: by then every token it covers has expired.
+// It is safe for concurrent use.
+type MemoryRevocationStore struct {
+	ttl time.Duration
+
+	mu    sync.RWMutex
+	byJTI map[uuid.UUID]Revocation // single-token revocations
+	rules []Revocation             // subject / tenant / issued-before revocations
+}
+
+var _ RevocationStore = (*MemoryRevocationStore)(nil)
+
+// NewMemoryRevocationStore returns an empty store whose entries expire after
+// ttl.
+func NewMemoryRevocationStore(ttl time.Duration) (*MemoryRevocationStore, error) {
+	if ttl <= 0 {
+		return nil, errors.New("revocation ttl must be positive")
+	}
+	return &MemoryRevocationStore{ttl: ttl, byJTI: map[uuid.UUID]Revocation{}}, nil
+}
+
+// Revoke adds r, filling in IssuedBefore and ExpiresAt if zero. A revocation
+// without selector or IssuedBefore fails with ErrUnscopedRevocation.
+func (s *MemoryRevocationStore) Revoke(_ context.Context, r Revocation) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	s.add(s.normalize(r, time.Now()))
+	return nil
+}
+
+// IsRevoked reports whether an unexpired revocation matches tok.
+func (s *MemoryRevocationStore) IsRevoked(_ context.Context, tok TokenInfo) (bool, error) {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, ok := s.byJTI[tok.JTI]; ok && now.Before(r.ExpiresAt) && r.matches(tok) {
+		return true, nil
+	}
+	for _, r := range s.rules {
+		if now.Before(r.ExpiresAt) && r.matches(tok) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryRevocationStore) normalize(r Revocation, now time.Time) Revocation {
+	if r.IssuedBefore.IsZero() {
+		r.IssuedBefore = now
+	}
+	if r.ExpiresAt.IsZero() {
+		r.ExpiresAt = now.Add(s.ttl)
+	}
+	return r
+}
+
+// add stores r and drops expired entries.
+func (s *MemoryRevocationStore) add(r Revocation) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jti, e := range s.byJTI {
+		if !now.Before(e.ExpiresAt) {
+			delete(s.byJTI, jti)
+		}
+	}
+	s.rules = slices.DeleteFunc(s.rules, func(e Revocation) bool { return !now.Before(e.ExpiresAt) })
+	if r.JTI != uuid.Nil && r.Subject == "" && r.TenantID == uuid.Nil {
+		s.byJTI[r.JTI] = r
+		return
+	}
+	s.rules = append(s.rules, r)
+}
+
+// entries returns the unexpired revocations.
+func (s *MemoryRevocationStore) entries() []Revocation {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Revocation, 0, len(s.byJTI)+len(s.rules))
+	for _, r := range s.byJTI {
+		if now.Before(r.ExpiresAt) {
+			out = append(out, r)
+		}
+	}
+	for _, r := range s.rules {
+		if now.Before(r.ExpiresAt) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// replace swaps the store's contents for entries, normalized as if stored at
+// written.
+func (s *MemoryRevocationStore) replace(entries []Revocation, written time.Time) {
+	fresh := &MemoryRevocationStore{ttl: s.ttl, byJTI: map[uuid.UUID]Revocation{}}
+	for _, r := range entries {
+		fresh.add(fresh.normalize(r, written))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byJTI, s.rules = fresh.byJTI, fresh.rules
+}
+
+// FileRevocationStore is a MemoryRevocationStore persisted to a JSON file, so
+// revocations survive restarts. Revoke rewrites the file atomically; changes
+// made by other writers (e.g. a mounted ConfigMap) are picked up on the next
+// check, at most once a second; their entries without issued_before or
+// expires_at count from the file's modification time. If a reload fails the
+// last good entries stay.
+type FileRevocationStore struct {
+	path string
+	mem  *MemoryRevocationStore
+
+	writeMu   sync.Mutex // serializes reloads with Revoke's read-modify-write
+	mu        sync.Mutex
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+var _ RevocationStore = (*FileRevocationStore)(nil)
+
+// NewFileRevocationStore loads path (a missing file is an empty list) with
+// entries expiring after ttl.
+func NewFileRevocationStore(path string, ttl time.Duration) (*FileRevocationStore, error) {
+	mem, err := NewMemoryRevocationStore(ttl)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileRevocationStore{path: path, mem: mem}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// IsRevoked reports whether an unexpired revocation matches tok, first
+// reloading the file if it changed.
+func (s *FileRevocationStore) IsRevoked(ctx context.Context, tok TokenInfo) (bool, error) {
+	s.mu.Lock()
+	due := time.Since(s.checkedAt) >= fileKeySourceCheckInterval
+	s.mu.Unlock()
+	if due {
+		s.writeMu.Lock()
+		_ = s.reloadIfChanged()
+		s.writeMu.Unlock()
+	}
+	return s.mem.IsRevoked(ctx, tok)
+}
+
+// Revoke adds r and persists the unexpired entries. A revocation without
+// selector or IssuedBefore fails with ErrUnscopedRevocation.
+func (s *FileRevocationStore) Revoke(ctx context.Context, r Revocation) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.reloadIfChanged(); err != nil {
+		return err
+	}
+	_ = s.mem.Revoke(ctx, r)
+	return s.save()
+}
+
+// Reload reads the file now. On error the previous entries are kept.
+func (s *FileRevocationStore) Reload() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.load(info)
+}
+
+func (s *FileRevocationStore) reloadIfChanged() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	s.mu.Lock()
+	s.checkedAt = time.Now()
+	unchanged := info == nil || (info.ModTime().Equal(s.modTime) && info.Size() == s.size)
+	s.mu.Unlock()
+	if err != nil || unchanged {
+		return err
+	}
+	return s.load(info)
+}
+
+func (s *FileRevocationStore) load(info os.FileInfo) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var entries []Revocation
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.mem.replace(entries, info.ModTime())
+	s.mu.Lock()
+	s.modTime, s.size, s.checkedAt = info.ModTime(), info.Size(), time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// save writes the unexpired entries atomically (temp file + rename).
+func (s *FileRevocationStore) save() error {
+	data, err := json.MarshalIndent(s.mem.entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".revocations-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.modTime, s.size = info.ModTime(), info.Size()
+	s.mu.Unlock()
+	return nil
+}
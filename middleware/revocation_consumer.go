@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+)
+
+// ConsumeRevocations feeds token.revoked events from topic into store until
+// ctx is done, e.g. in its own goroutine next to a store passed to
+// WithRevocationChecker. A revocation without IssuedBefore counts from the
+// event's timestamp, except one without JTI, Subject or TenantID: that would
+// revoke every token, so it needs an explicit issued_before and is otherwise
+// rejected with ErrUnscopedRevocation. Events of other types are skipped;
+// undecodable or rejected messages and store errors are passed to onErr (which
+// may be nil) and skipped. It returns nil once ctx is done, or the consumer's
+// error if Receive fails. adapters.KafkaConsumerWrapper reads from Kafka.
+func ConsumeRevocations(ctx context.Context, consumer interfaces.Consumer, topic string, store RevocationStore, onErr func(ctx context.Context, err error)) error {
+	report := func(err error) {
+		if onErr != nil {
+			onErr(ctx, err)
+		}
+	}
+	for {
+		msg, err := consumer.Receive(ctx, topic)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("receive revocations from %s: %w", topic, err)
+		}
+
+		ev, err := messageEvent(msg.Value)
+		if err != nil {
+			report(fmt.Errorf("revocation message on %s: %w", topic, err))
+			continue
+		}
+		if ev.EventType != EventTypeTokenRevoked {
+			continue
+		}
+		p, err := DecodeRevocationPayload(ev)
+		if err != nil {
+			report(fmt.Errorf("revocation event %s: %w", ev.Id, err))
+			continue
+		}
+		r := p.Revocation
+		if err := r.validate(); err != nil {
+			report(fmt.Errorf("revocation event %s: %w", ev.Id, err))
+			continue
+		}
+		if r.IssuedBefore.IsZero() {
+			r.IssuedBefore = ev.Timestamp
+		}
+		if err := store.Revoke(ctx, r); err != nil {
+			report(fmt.Errorf("store revocation event %s: %w", ev.Id, err))
+		}
+	}
+}
+
+// messageEvent decodes a consumed message value into an event: raw JSON
+// (as read from Kafka) or an already-decoded event.
+func messageEvent(v any) (sdkmodels.EventJson, error) {
+	var ev sdkmodels.EventJson
+	switch m := v.(type) {
+	case sdkmodels.EventJson:
+		return m, nil
+	case *sdkmodels.EventJson:
+		if m != nil {
+			return *m, nil
+		}
+		return ev, errors.New("nil event")
+	case json.RawMessage:
+		return ev, json.Unmarshal(m, &ev)
+	case []byte:
+		return ev, json.Unmarshal(m, &ev)
+	case string:
+		return ev, json.Unmarshal([]byte(m), &ev)
+	}
+	return ev, fmt.Errorf("unsupported message value %T", v)
+}
@@ -0,0 +1,241 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+)
+
+// tokenJTI returns the jti of a minted token.
+func tokenJTI(t *testing.T, tok string) uuid.UUID {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	_, _, err := new(jwt.Parser).ParseUnverified(tok, claims)
+	require.NoError(t, err)
+	jti, err := uuid.Parse(claims["jti"].(string))
+	require.NoError(t, err)
+	return jti
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := middleware.NewMemoryRevocationStore(time.Hour)
+	require.NoError(t, err)
+	tenant := uuid.New()
+	issued := time.Now().Add(-time.Minute)
+	tok := middleware.TokenInfo{JTI: uuid.New(), Subject: "alice@example.com", TenantID: tenant, IssuedAt: issued}
+	revoked := func(tok middleware.TokenInfo) bool {
+		ok, err := store.IsRevoked(ctx, tok)
+		require.NoError(t, err)
+		return ok
+	}
+
+	assert.False(t, revoked(tok))
+
+	require.NoError(t, store.Revoke(ctx, middleware.Revocation{JTI: tok.JTI}))
+	assert.True(t, revoked(tok), "by jti")
+
+	bob := middleware.TokenInfo{JTI: uuid.New(), Subject: "bob@example.com", TenantID: tenant, IssuedAt: issued}
+	require.NoError(t, store.Revoke(ctx, middleware.Revocation{Subject: bob.Subject}))
+	assert.True(t, revoked(bob), "by subject")
+	bob.IssuedAt = time.Now().Add(time.Minute)
+	assert.False(t, revoked(bob), "tokens issued after the revocation stay valid")
+
+	other := middleware.TokenInfo{JTI: uuid.New(), Subject: "carol@example.com", TenantID: uuid.New(), IssuedAt: issued}
+	assert.False(t, revoked(other))
+	require.NoError(t, store.Revoke(ctx, middleware.Revocation{TenantID: other.TenantID, IssuedBefore: issued.Add(time.Second)}))
+	assert.True(t, revoked(other), "by tenant")
+
+	assert.ErrorIs(t, store.Revoke(ctx, middleware.Revocation{}), middleware.ErrUnscopedRevocation)
+	assert.False(t, revoked(middleware.TokenInfo{JTI: uuid.New(), Subject: "dave@example.com", IssuedAt: issued}))
+	require.NoError(t, store.Revoke(ctx, middleware.Revocation{IssuedBefore: time.Now()}))
+	assert.True(t, revoked(middleware.TokenInfo{JTI: uuid.New(), Subject: "dave@example.com", IssuedAt: issued}), "everything issued before T")
+
+	short, err := middleware.NewMemoryRevocationStore(time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, short.Revoke(ctx, middleware.Revocation{JTI: tok.JTI, ExpiresAt: time.Now().Add(-time.Second)}))
+	ok, err := short.IsRevoked(ctx, tok)
+	require.NoError(t, err)
+	assert.False(t, ok, "expired entries are ignored")
+
+	_, err = middleware.NewMemoryRevocationStore(0)
+	assert.Error(t, err)
+}
+
+func TestFileRevocationStore_PersistsAndReloads(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	tok := middleware.TokenInfo{JTI: uuid.New(), Subject: "alice@example.com", IssuedAt: time.Now().Add(-time.Minute)}
+
+	store, err := middleware.NewFileRevocationStore(path, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.Revoke(ctx, middleware.Revocation{JTI: tok.JTI}))
+
+	// A restart keeps the revocation.
+	store, err = middleware.NewFileRevocationStore(path, time.Hour)
+	require.NoError(t, err)
+	ok, err := store.IsRevoked(ctx, tok)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// An external edit is picked up without a restart.
+	bob := middleware.TokenInfo{JTI: uuid.New(), Subject: "bob@example.com", IssuedAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, os.WriteFile(path, []byte(`[{"subject":"bob@example.com"}]`), 0o600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+	assert.Eventually(t, func() bool {
+		ok, err := store.IsRevoked(ctx, bob)
+		return err == nil && ok
+	}, 3*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+	_, err = middleware.NewFileRevocationStore(path, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestFileRevocationStore_ConcurrentRevokeAndReload(t *testing.T) {
+	ctx := context.Background()
+	store, err := middleware.NewFileRevocationStore(filepath.Join(t.TempDir(), "revocations.json"), time.Hour)
+	require.NoError(t, err)
+	issued := time.Now().Add(-time.Minute)
+
+	const n = 200
+	jtis := make([]uuid.UUID, n)
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_ = store.Reload()
+				_, _ = store.IsRevoked(ctx, middleware.TokenInfo{JTI: uuid.New(), IssuedAt: issued})
+			}
+		}()
+	}
+	var writers sync.WaitGroup
+	for i := range jtis {
+		jtis[i] = uuid.New()
+		writers.Add(1)
+		go func(jti uuid.UUID) {
+			defer writers.Done()
+			assert.NoError(t, store.Revoke(ctx, middleware.Revocation{JTI: jti}))
+		}(jtis[i])
+	}
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	require.NoError(t, store.Reload())
+	for _, jti := range jtis {
+		ok, err := store.IsRevoked(ctx, middleware.TokenInfo{JTI: jti, IssuedAt: issued})
+		require.NoError(t, err)
+		assert.True(t, ok, "revocation %s was lost", jti)
+	}
+}
+
+func TestAuthN_RevokedTokenRejected(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	store, err := middleware.NewMemoryRevocationStore(time.Hour)
+	require.NoError(t, err)
+	e, mock := newAuthAppWithProducer(t, pubPEM, middleware.WithRevocationChecker(store))
+
+	tok := mintToken(t, priv, tokenOpts{cls: "user"})
+	require.Equal(t, http.StatusOK, doGet(t, e, "/protected/", tok).Code)
+
+	require.NoError(t, store.Revoke(context.Background(), middleware.Revocation{JTI: tokenJTI(t, tok)}))
+	rec := doGet(t, e, "/protected/", tok)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error_description="The access token has been revoked"`)
+
+	require.Eventually(t, func() bool {
+		ev, ok := mock.Value().(sdkmodels.EventJson)
+		return ok && ev.EventType == middleware.EventTypeLoginFailure
+	}, time.Second, 10*time.Millisecond)
+	payload, err := middleware.DecodeLoginPayload(mock.Value().(sdkmodels.EventJson))
+	require.NoError(t, err)
+	assert.Equal(t, middleware.ReasonRevoked, payload.Reason)
+
+	// Other tokens of the same user are unaffected.
+	assert.Equal(t, http.StatusOK, doGet(t, e, "/protected/", mintToken(t, priv, tokenOpts{cls: "user"})).Code)
+}
+
+type failingChecker struct{}
+
+func (failingChecker) IsRevoked(context.Context, middleware.TokenInfo) (bool, error) {
+	return false, assert.AnError
+}
+
+func TestAuthN_RevocationCheckFailsClosed(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	e := newAuthApp(t, pubPEM, testIssuer, middleware.WithRevocationChecker(failingChecker{}))
+
+	rec := doGet(t, e, "/protected/", mintToken(t, priv, tokenOpts{cls: "user"}))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestConsumeRevocations(t *testing.T) {
+	store, err := middleware.NewMemoryRevocationStore(time.Hour)
+	require.NoError(t, err)
+	consumer := fakes.NewMockConsumer(4)
+	var bad atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- middleware.ConsumeRevocations(ctx, consumer, "ds.auth.revocations.v1", store,
+			func(context.Context, error) { bad.Add(1) })
+	}()
+
+	events := middleware.NewEventBuilder(fakes.NewConfig("dp", "core", "idp", "v0.0.1", uuid.New(), 512))
+	event := func(eventType string, payload any) []byte {
+		b, err := json.Marshal(events.Build(context.Background(), eventType, payload))
+		require.NoError(t, err)
+		return b
+	}
+	consumer.Push("not json")
+	consumer.Push(event(middleware.EventTypeLoginSuccess, map[string]any{"subject": "x"}))
+	consumer.Push(event(middleware.EventTypeTokenRevoked, middleware.RevocationPayload{
+		SchemaVersion: middleware.PayloadSchemaVersion,
+	}))
+	consumer.Push(event(middleware.EventTypeTokenRevoked, middleware.RevocationPayload{
+		SchemaVersion: middleware.PayloadSchemaVersion,
+		Revocation:    middleware.Revocation{Subject: "alice@example.com"},
+	}))
+
+	alice := middleware.TokenInfo{JTI: uuid.New(), Subject: "alice@example.com", IssuedAt: time.Now().Add(-time.Minute)}
+	assert.Eventually(t, func() bool {
+		ok, err := store.IsRevoked(context.Background(), alice)
+		return err == nil && ok
+	}, time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 2, bad.Load(), "the undecodable message and the unscoped revocation are reported")
+	bob := middleware.TokenInfo{JTI: uuid.New(), Subject: "bob@example.com", IssuedAt: time.Now().Add(-time.Minute)}
+	ok, err := store.IsRevoked(context.Background(), bob)
+	require.NoError(t, err)
+	assert.False(t, ok, "a revocation without selector or issued_before revokes nothing")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
+)
+
+// ScopeRequirement is the OAuth scopes a route needs: at least one of AnyOf
+// (when set) and every one of AllOf. Set both to require, e.g., a base scope
+// plus one of several elevated ones.
+type ScopeRequirement struct {
+	AnyOf []string
+	AllOf []string
+}
+
+// satisfiedBy reports whether the granted scopes meet r.
+func (r ScopeRequirement) satisfiedBy(granted []string) bool {
+	for _, s := range r.AllOf {
+		if !slices.Contains(granted, s) {
+			return false
+		}
+	}
+	if len(r.AnyOf) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.AnyOf, func(s string) bool { return slices.Contains(granted, s) })
+}
+
+// scopes lists the scopes r mentions, AllOf first, without duplicates.
+func (r ScopeRequirement) scopes() []string {
+	var out []string
+	for _, s := range append(slices.Clone(r.AllOf), r.AnyOf...) {
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// RequireScopes rejects requests whose principal's scopes (`scope` / `scp`)
+// do not meet req, with 403 and an RFC 6750 challenge
+// `Bearer error="insufficient_scope", scope="..."`, and emits an
+// authz.denied event. It must run after AuthenticationMiddleware; a request
+// without a principal gets a 401. An empty requirement lets every
+// authenticated request through.
+func RequireScopes(cfg interfaces.Config, logger interfaces.Logger, producer *adapters.ProducerAdapter, topic string, req ScopeRequirement) echo.MiddlewareFunc {
+	required := req.scopes()
+	events := NewEventBuilder(cfg)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			p, ok := requestctx.GetPrincipal(ctx)
+			if ok && req.satisfiedBy(p.Scopes) {
+				return next(c)
+			}
+
+			status, code, errMessage := http.StatusForbidden, "forbidden", "Insufficient scope"
+			if !ok {
+				status, code, errMessage = http.StatusUnauthorized, "unauthorized", "Principal not found"
+			}
+			logger.Error(ctx, "%s: have %v, need %v", errMessage, p.Scopes, required)
+
+			var message *string
+			if val := c.Request().Header.Get("X-Message"); val != "" {
+				message = &val
+			}
+			event := events.Build(ctx, EventTypeAuthzDenied, AuthzPayload{
+				SchemaVersion:  PayloadSchemaVersion,
+				StatusCode:     status,
+				Subject:        p.ID,
+				Error:          &errMessage,
+				Path:           c.Path(),
+				UserAgent:      c.Request().UserAgent(),
+				RemoteAddr:     c.Request().RemoteAddr,
+				RequiredScopes: required,
+			}, WithEventTenant(p.TenantID), WithEventMessage(message))
+			sendEventAsync(ctx, producer, logger, topic, event, EventTypeAuthzDenied)
+
+			// Answer in the scheme the token came with (DPoP under WithDPoP).
+			scheme := "Bearer"
+			if authz := c.Request().Header.Get(echo.HeaderAuthorization); len(authz) > 5 && strings.EqualFold(authz[:5], "DPoP ") {
+				scheme = "DPoP"
+			}
+			if ok {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, formatChallenge(scheme,
+					"error", "insufficient_scope",
+					"scope", strings.Join(required, " "),
+				))
+			} else {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, scheme)
+			}
+			body := WrapErr(c, code)
+			return &echo.HTTPError{Code: status, Message: errorBody{body}, Internal: body}
+		}
+	}
+}
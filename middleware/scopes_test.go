@@ -0,0 +1,111 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
+
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware"
+	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
+)
+
+// newScopedApp serves /scoped behind AuthenticationMiddleware and
+// RequireScopes(req), exposing the producer RequireScopes emits to.
+func newScopedApp(t *testing.T, pubPEM string, req middleware.ScopeRequirement) (*echo.Echo, *fakes.MockProducer) {
+	t.Helper()
+	e := echo.New()
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", uuid.New(), 512)
+	cfg.SetIssuer(testIssuer)
+	logger := &fakes.MockLogger{}
+
+	authMW, err := middleware.AuthenticationMiddleware(cfg, logger, pubPEM,
+		&adapters.ProducerAdapter{Producer: &fakes.MockProducer{}}, "ds.test.v1")
+	require.NoError(t, err)
+	mock := &fakes.MockProducer{}
+	scoped := middleware.RequireScopes(cfg, logger, &adapters.ProducerAdapter{Producer: mock}, "ds.test.v1", req)
+
+	e.GET("/scoped", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, authMW, scoped)
+	return e, mock
+}
+
+func TestRequireScopes(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	withScopes := func(extra map[string]any) string {
+		return mintToken(t, priv, tokenOpts{cls: "user", extra: extra})
+	}
+
+	cases := []struct {
+		name  string
+		req   middleware.ScopeRequirement
+		token string
+		want  int
+	}{
+		{"allOf from scope", middleware.ScopeRequirement{AllOf: []string{"orders:read", "orders:write"}},
+			withScopes(map[string]any{"scope": "orders:read orders:write"}), http.StatusOK},
+		{"allOf from scp", middleware.ScopeRequirement{AllOf: []string{"orders:read", "orders:write"}},
+			withScopes(map[string]any{"scp": []string{"orders:read", "orders:write"}}), http.StatusOK},
+		{"allOf across scope and scp", middleware.ScopeRequirement{AllOf: []string{"orders:read", "orders:write"}},
+			withScopes(map[string]any{"scope": "orders:read", "scp": "orders:write"}), http.StatusOK},
+		{"allOf missing one", middleware.ScopeRequirement{AllOf: []string{"orders:read", "orders:write"}},
+			withScopes(map[string]any{"scope": "orders:read"}), http.StatusForbidden},
+		{"anyOf", middleware.ScopeRequirement{AnyOf: []string{"orders:admin", "orders:write"}},
+			withScopes(map[string]any{"scope": "orders:write"}), http.StatusOK},
+		{"anyOf none", middleware.ScopeRequirement{AnyOf: []string{"orders:admin", "orders:write"}},
+			withScopes(map[string]any{"scope": "orders:read"}), http.StatusForbidden},
+		{"allOf and anyOf", middleware.ScopeRequirement{AllOf: []string{"orders:read"}, AnyOf: []string{"orders:admin", "orders:write"}},
+			withScopes(map[string]any{"scope": "orders:write"}), http.StatusForbidden},
+		{"no scopes", middleware.ScopeRequirement{AllOf: []string{"orders:read"}},
+			withScopes(nil), http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, _ := newScopedApp(t, pubPEM, tc.req)
+			assert.Equal(t, tc.want, doGet(t, e, "/scoped", tc.token).Code)
+		})
+	}
+}
+
+func TestRequireScopes_DeniedChallengeAndEvent(t *testing.T) {
+	priv, pubPEM, err := fakes.GenerateRSAPairPEM()
+	require.NoError(t, err)
+	e, mock := newScopedApp(t, pubPEM, middleware.ScopeRequirement{
+		AllOf: []string{"orders:read"}, AnyOf: []string{"orders:admin", "orders:write"},
+	})
+
+	tok := mintToken(t, priv, tokenOpts{cls: "user", sub: "alice@example.com", extra: map[string]any{"scope": "orders:read"}})
+	rec := doGet(t, e, "/scoped", tok)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, `Bearer error="insufficient_scope", scope="orders:read orders:admin orders:write"`,
+		rec.Header().Get("WWW-Authenticate"))
+	assert.Contains(t, rec.Body.String(), `"code":"forbidden"`)
+
+	require.True(t, mock.WaitForSend(time.Second))
+	ev, ok := mock.Value().(sdkmodels.EventJson)
+	require.True(t, ok)
+	assert.Equal(t, middleware.EventTypeAuthzDenied, ev.EventType)
+	payload, err := middleware.DecodeAuthzPayload(ev)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, payload.StatusCode)
+	assert.Equal(t, "alice@example.com", payload.Subject)
+	assert.Equal(t, []string{"orders:read", "orders:admin", "orders:write"}, payload.RequiredScopes)
+}
+
+func TestRequireScopes_WithoutPrincipal(t *testing.T) {
+	e := echo.New()
+	cfg := fakes.NewConfig("dp", "core", "test-svc", "v0.0.1", uuid.New(), 512)
+	e.GET("/scoped", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+		middleware.RequireScopes(cfg, &fakes.MockLogger{}, &adapters.ProducerAdapter{Producer: &fakes.MockProducer{}}, "ds.test.v1",
+			middleware.ScopeRequirement{AllOf: []string{"orders:read"}}))
+
+	rec := doGet(t, e, "/scoped", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -3,21 +3,18 @@ package middleware
 import (
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 
-	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
 	"github.com/grasp-labs/ds-go-commonmodels/v3/commonmodels/enum/status"
 
-	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/utils"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/adapters"
 	ctx "github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/claims"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/interfaces"
-	"github.com/grasp-labs/ds-go-echo-middleware/v3/middleware/requestctx"
 )
 
 // UsageMiddleware returns an Echo middleware that emits usage report to Kafka.
 func UsageMiddleware(cfg interfaces.Config, logger interfaces.Logger, producer *adapters.ProducerAdapter, topic string) echo.MiddlewareFunc {
+	events := NewEventBuilder(cfg)
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			request := c.Request()
@@ -36,10 +33,6 @@ func UsageMiddleware(cfg interfaces.Config, logger interfaces.Logger, producer *
 
 			endTimestamp := time.Now().UTC()
 
-			// Parse (or generate) request ID set by RequestID middleware
-			requestID := requestctx.GetOrNewRequestUUID(c.Request().Context())
-			sessionID := requestctx.GetOrNewSessionUUID(c.Request().Context())
-
 			tenantID, err := claims.GetTenantId()
 			if err != nil {
 				logger.Error(c.Request().Context(), "invalid tenant_id from userContext: %s", claims.Rsc)
@@ -58,28 +51,18 @@ func UsageMiddleware(cfg interfaces.Config, logger interfaces.Logger, producer *
 				message = &val
 			}
 
-			event := sdkmodels.EventJson{
-				Id:          uuid.New(),
-				RequestId:   requestID,
-				SessionId:   sessionID,
-				TenantId:    tenantID,
-				OwnerId:     ownerID,
-				Timestamp:   startTimestamp,
-				EventType:   "usage.report",
-				EventSource: utils.CreateServicePrincipleID(cfg),
-				Message:     message,
-				Payload: &map[string]any{
-					"product_id":   cfg.ProductID(),
-					"memory_mb":    cfg.MemoryLimitMB(),
-					"start_time":   startTimestamp,
-					"end_time":     endTimestamp,
-					"status":       status.Draft,
-					"user_id":      claims.Sub,
-					"service_name": cfg.Name(),
-				},
-			}
+			event := events.Build(request.Context(), EventTypeUsageReport, UsagePayload{
+				SchemaVersion: PayloadSchemaVersion,
+				ProductID:     cfg.ProductID(),
+				MemoryMB:      cfg.MemoryLimitMB(),
+				StartTime:     startTimestamp,
+				EndTime:       endTimestamp,
+				Status:        status.Draft,
+				UserID:        claims.Sub,
+				ServiceName:   cfg.Name(),
+			}, WithEventTenant(tenantID), WithEventOwner(ownerID), WithEventMessage(message), WithEventTimestamp(startTimestamp))
 
-			sendEventAsync(request.Context(), producer, logger, topic, event, "usage.report")
+			sendEventAsync(request.Context(), producer, logger, topic, event, EventTypeUsageReport)
 
 			return callErr
 		}
@@ -2,6 +2,7 @@ package middleware_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	sdkmodels "github.com/grasp-labs/ds-event-stream-go-sdk/models"
 	"github.com/grasp-labs/ds-go-echo-middleware/v3/internal/fakes"
@@ -87,43 +89,21 @@ func TestUsageMiddleware_BasicFlow(t *testing.T) {
 	assert.NotEqual(t, uuid.Nil, eventJson.Id, "Event ID should be set")
 	assert.NotNil(t, eventJson.Payload, "Payload should not be nil")
 
-	// Extract Payload map for detailed checks
-	payloadPtr, ok := eventJson.Payload.(*map[string]any)
-	assert.True(t, ok, "Payload should be a map[string]any")
+	// Extract Payload for detailed checks
+	usagePayload, ok := eventJson.Payload.(middleware.UsagePayload)
+	assert.True(t, ok, "Payload should be a middleware.UsagePayload")
 
 	// Key usage fields
 	assert.Equal(t, requestID, eventJson.RequestId)
-	assert.NotNil(t, payloadPtr)
-	payloadMap := *payloadPtr
-	assert.Equal(t, cfg.ProductID(), payloadMap["product_id"])
-	assert.Equal(t, cfg.MemoryLimitMB(), payloadMap["memory_mb"])
-	assert.NotEmpty(t, payloadMap["start_time"], "Start time should be set")
-	assert.NotEmpty(t, payloadMap["end_time"], "End time should be set")
-	assert.NotEmpty(t, payloadMap["status"], "Status should be set")
-	assert.Equal(t, "user@email.com", payloadMap["user_id"])
-	assert.Equal(t, cfg.Name(), payloadMap["service_name"])
-
-	// ProductID type and value
-	productIdUUID, ok := payloadMap["product_id"].(uuid.UUID)
-	assert.True(t, ok, "Product ID should be uuid.UUID")
-
-	assert.Equal(t, cfg.ProductID(), productIdUUID)
-
-	// MemoryMB type and value
-	memoryMbInt, ok := payloadMap["memory_mb"].(int16)
-	assert.True(t, ok, "Memory MB should be int16")
-	assert.GreaterOrEqual(t, int(memoryMbInt), 0, "Memory MB should be non-negative")
-
-	// StartTime type
-	_, ok = payloadMap["start_time"].(time.Time)
-	assert.True(t, ok, "Start time should be time.Time")
-
-	// EndTime type
-	_, ok = payloadMap["end_time"].(time.Time)
-	assert.True(t, ok, "End time should be time.Time")
-
-	// Status type
-	assert.NotNil(t, payloadMap["status"], "Status should be set")
+	assert.Equal(t, middleware.PayloadSchemaVersion, usagePayload.SchemaVersion)
+	assert.Equal(t, cfg.ProductID(), usagePayload.ProductID)
+	assert.Equal(t, cfg.MemoryLimitMB(), usagePayload.MemoryMB)
+	assert.False(t, usagePayload.StartTime.IsZero(), "Start time should be set")
+	assert.False(t, usagePayload.EndTime.IsZero(), "End time should be set")
+	assert.False(t, usagePayload.EndTime.Before(usagePayload.StartTime), "End time should not precede start time")
+	assert.NotEmpty(t, usagePayload.Status, "Status should be set")
+	assert.Equal(t, "user@email.com", usagePayload.UserID)
+	assert.Equal(t, cfg.Name(), usagePayload.ServiceName)
 
 	// OwnerID presence and value
 	assert.NotNil(t, eventJson.OwnerId, "Owner ID should not be nil")
@@ -170,3 +150,27 @@ func TestUsageMiddleware_MissingUserContext(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.False(t, mock.Called(), "Producer should not have been called when user context is missing")
 }
+
+func TestUsageMiddleware_AfterShutdown(t *testing.T) {
+	e := echo.New()
+	cfg := fakes.NewConfig("dp", "core", "new-service", "v1.0.0-alpha.1", uuid.New(), 1024*2)
+	logger := &fakes.MockLogger{}
+	mock := &fakes.MockProducer{}
+	producer := &adapters.ProducerAdapter{Producer: mock}
+	_, err := producer.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	e.Use(middleware.UsageMiddleware(cfg, logger, producer, "test_topic"))
+	e.POST("/api/usage/v1/", func(c echo.Context) error {
+		c.Set("userContext", fakes.NewTestUserContext("user@email.com", uuid.NewString()+":MockName"))
+		return c.NoContent(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/usage/v1/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, producer.Closed())
+	assert.Equal(t, "Dropped usage.report event: producer is shut down", logger.LastMessage())
+	assert.False(t, mock.Called())
+}